@@ -0,0 +1,59 @@
+// Provides helpers which tie the mapper to the Mongo-Go Driver's bulk write API.
+//
+// It is kept in it's own package, so that users who only need the mapping don't import the driver's mongo package
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/naamancurtis/mongo-go-struct-to-bson/mapper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+)
+
+// ErrNotASlice is returned when the value to be written isn't a slice or array of structs
+var ErrNotASlice = errors.New("not a slice")
+
+// Writer is the subset of a *mongo.Collection which is used to perform bulk writes,
+// allowing the collection to be swapped out (ie. mocked in tests)
+type Writer interface {
+	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+}
+
+// BulkInsert maps each struct within the slice (factoring in any options passed) and inserts them
+// into the collection in a single bulk write. Any bulk write options are passed through to the driver.
+//
+// Returns nil if the slice is empty, as there is nothing to insert
+func BulkInsert(ctx context.Context, coll Writer, slice interface{}, opts *mapper.MappingOpts, writeOpts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	models, err := InsertModels(slice, opts)
+	if err != nil || len(models) == 0 {
+		return nil, err
+	}
+	return coll.BulkWrite(ctx, models, writeOpts...)
+}
+
+// InsertModels maps each struct within the slice (factoring in any options passed)
+// and returns an insert model for each of them, in the same order as the slice
+//
+// An error is returned if the slice isn't a slice or array, or if any of it's structs can't be mapped
+func InsertModels(slice interface{}, opts *mapper.MappingOpts) ([]mongo.WriteModel, error) {
+	v := reflect.ValueOf(slice)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected slice or array of structs, got %s: %w", v.Kind(), ErrNotASlice)
+	}
+
+	models := make([]mongo.WriteModel, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		doc, err := mapper.ConvertStructToBSONMapE(v.Index(i).Interface(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to map element %d: %w", i, err)
+		}
+		models = append(models, mongo.NewInsertOneModel().SetDocument(doc))
+	}
+	return models, nil
+}
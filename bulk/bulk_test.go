@@ -0,0 +1,91 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"github.com/naamancurtis/mongo-go-struct-to-bson/mapper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mockCollection records the bulk writes it receives, rather than writing them to a collection
+type mockCollection struct {
+	models    []mongo.WriteModel
+	writeOpts []*options.BulkWriteOptions
+	calls     int
+	err       error
+}
+
+func (m *mockCollection) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	m.calls++
+	m.models = models
+	m.writeOpts = opts
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &mongo.BulkWriteResult{InsertedCount: int64(len(models))}, nil
+}
+
+var _ = Describe("BulkInsert", func() {
+	type user struct {
+		Name string `bson:"name"`
+		Age  int    `bson:"age,omitempty"`
+	}
+
+	var coll *mockCollection
+
+	BeforeEach(func() {
+		coll = &mockCollection{}
+	})
+
+	It("should insert each of the mapped structs in one bulk write", func() {
+		result, err := BulkInsert(context.Background(), coll, []user{{Name: "Jane", Age: 30}, {Name: "John"}}, nil)
+		Expect(err).To(BeNil())
+		Expect(result.InsertedCount).To(Equal(int64(2)))
+		Expect(coll.calls).To(Equal(1))
+		Expect(coll.models).To(Equal([]mongo.WriteModel{
+			mongo.NewInsertOneModel().SetDocument(bson.M{"name": "Jane", "age": 30}),
+			mongo.NewInsertOneModel().SetDocument(bson.M{"name": "John"}),
+		}))
+	})
+
+	It("should factor in the mapping options and pass through the bulk write options", func() {
+		writeOpts := options.BulkWrite().SetOrdered(false)
+		_, err := BulkInsert(context.Background(), coll, []*user{{Name: "Jane"}}, &mapper.MappingOpts{ContextFields: map[string]interface{}{"tenantId": "t1"}}, writeOpts)
+		Expect(err).To(BeNil())
+		Expect(coll.models).To(Equal([]mongo.WriteModel{
+			mongo.NewInsertOneModel().SetDocument(bson.M{"name": "Jane", "tenantId": "t1"}),
+		}))
+		Expect(coll.writeOpts).To(Equal([]*options.BulkWriteOptions{writeOpts}))
+	})
+
+	It("should not write anything if the slice is empty", func() {
+		result, err := BulkInsert(context.Background(), coll, []user{}, nil)
+		Expect(result).To(BeNil())
+		Expect(err).To(BeNil())
+		Expect(coll.calls).To(Equal(0))
+	})
+
+	It("should return the error from the collection", func() {
+		coll.err = errors.New("write failed")
+		_, err := BulkInsert(context.Background(), coll, []user{{Name: "Jane"}}, nil)
+		Expect(err).To(MatchError("write failed"))
+	})
+
+	It("should return an error if a slice isn't passed", func() {
+		result, err := BulkInsert(context.Background(), coll, user{Name: "Jane"}, nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrNotASlice)).To(BeTrue())
+		Expect(coll.calls).To(Equal(0))
+	})
+
+	It("should return an error reporting the element which couldn't be mapped", func() {
+		_, err := BulkInsert(context.Background(), coll, []interface{}{user{Name: "Jane"}, "Test String"}, nil)
+		Expect(errors.Is(err, mapper.ErrNotAStruct)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("element 1")))
+		Expect(coll.calls).To(Equal(0))
+	})
+})
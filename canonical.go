@@ -0,0 +1,97 @@
+package mapper
+
+import (
+	"encoding/hex"
+	"go.mongodb.org/mongo-driver/bson"
+	"hash/fnv"
+	"reflect"
+	"sort"
+)
+
+// CanonicalBytes maps the struct (factoring in any options passed) and returns a canonical
+// BSON serialisation of it, where the keys of every document are sorted.
+//
+// As a bson.M has no defined order, marshalling it directly can produce different bytes
+// for logically equal documents. CanonicalBytes will always produce the same bytes for
+// logically equal structs, making it suitable for hashing or de-duplicating documents.
+func CanonicalBytes(s interface{}, opts *MappingOpts) ([]byte, error) {
+	m, err := ConvertStructToBSONMapE(s, opts)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalBytes(m)
+}
+
+// canonicalBytes marshals the document with all of it's keys sorted
+func canonicalBytes(doc interface{}) ([]byte, error) {
+	if d, ok := doc.(bson.D); ok {
+		doc = d.Map()
+	}
+
+	d, _ := canonicalise(doc).(bson.D)
+	if d == nil {
+		d = bson.D{}
+	}
+	return bson.Marshal(d)
+}
+
+// contentHash returns the hex encoded FNV-1a hash of the document's canonical serialisation
+func contentHash(doc interface{}) (string, error) {
+	b, err := canonicalBytes(doc)
+	if err != nil {
+		return "", err
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalise recursively converts any maps within the value into bson.D's
+// with sorted keys, so the value can be marshalled deterministically
+//
+// The keys of any bson.D's are sorted as well, so that a nested struct produces
+// the same bytes whether it was mapped into a bson.M or a bson.D (see ToBSOND)
+func canonicalise(val interface{}) interface{} {
+	if d, ok := val.(bson.D); ok {
+		out := make(bson.D, len(d))
+		for i, e := range d {
+			out[i] = bson.E{Key: e.Key, Value: canonicalise(e.Value)}
+		}
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+		return out
+	}
+
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return val
+		}
+
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		out := make(bson.D, len(keys))
+		for i, k := range keys {
+			out[i] = bson.E{Key: k.String(), Value: canonicalise(v.MapIndex(k).Interface())}
+		}
+		return out
+
+	case reflect.Slice:
+		// Byte slices are stored as binary, so need to be left as they are
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return val
+		}
+
+		out := make(bson.A, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = canonicalise(v.Index(i).Interface())
+		}
+		return out
+	}
+
+	return val
+}
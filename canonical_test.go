@@ -0,0 +1,123 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+var _ = Describe("CanonicalBytes", func() {
+	type nestedStruct struct {
+		TestField3 string `bson:"testField3"`
+		TestField4 int    `bson:"testField4"`
+	}
+
+	type reorderedNestedStruct struct {
+		TestField4 int    `bson:"testField4"`
+		TestField3 string `bson:"testField3"`
+	}
+
+	testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	testMap := map[string]int{"Test 1": 1, "Test 2": 2, "Test 3": 3, "Test 4": 4}
+
+	It("should produce identical bytes for structs with reordered fields", func() {
+		first, err := CanonicalBytes(
+			struct {
+				TestField1 string         `bson:"testField1"`
+				TestField2 time.Time      `bson:"testField2"`
+				Nested     nestedStruct   `bson:"nested"`
+				Slice      []nestedStruct `bson:"slice"`
+				Map        map[string]int `bson:"map"`
+			}{
+				TestField1: "Test String",
+				TestField2: testTime,
+				Nested:     nestedStruct{TestField3: "Test String", TestField4: 10},
+				Slice:      []nestedStruct{{TestField3: "Test String", TestField4: 10}},
+				Map:        testMap,
+			}, nil,
+		)
+		Expect(err).To(BeNil())
+
+		second, err := CanonicalBytes(
+			&struct {
+				Map        map[string]int          `bson:"map"`
+				Slice      []reorderedNestedStruct `bson:"slice"`
+				Nested     reorderedNestedStruct   `bson:"nested"`
+				TestField2 time.Time               `bson:"testField2"`
+				TestField1 string                  `bson:"testField1"`
+			}{
+				TestField1: "Test String",
+				TestField2: testTime,
+				Nested:     reorderedNestedStruct{TestField3: "Test String", TestField4: 10},
+				Slice:      []reorderedNestedStruct{{TestField3: "Test String", TestField4: 10}},
+				Map:        testMap,
+			}, nil,
+		)
+		Expect(err).To(BeNil())
+
+		Expect(first).To(Equal(second))
+	})
+
+	It("should produce different bytes for structs with different values", func() {
+		first, _ := CanonicalBytes(nestedStruct{TestField3: "Test String", TestField4: 10}, nil)
+		second, _ := CanonicalBytes(nestedStruct{TestField3: "Test String", TestField4: 11}, nil)
+		Expect(first).NotTo(Equal(second))
+	})
+
+	It("should produce an empty document if every field is omitted", func() {
+		result, err := CanonicalBytes(nestedStruct{}, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(err).To(BeNil())
+
+		expected, _ := bson.Marshal(bson.D{})
+		Expect(result).To(Equal(expected))
+	})
+
+	It("should return an error if a struct is not passed", func() {
+		result, err := CanonicalBytes("Test String", nil)
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
+
+var _ = Describe("The ContentHashKey option", func() {
+	type testStruct struct {
+		TestField1 string `bson:"testField1"`
+		TestField2 int    `bson:"testField2"`
+	}
+
+	type reorderedTestStruct struct {
+		TestField2 int    `bson:"testField2"`
+		TestField1 string `bson:"testField1"`
+	}
+
+	opts := &MappingOpts{ContentHashKey: "etag"}
+
+	It("should add the hash under the given key", func() {
+		result := ConvertStructToBSONMap(testStruct{TestField1: "Test String", TestField2: 10}, opts)
+		Expect(result).To(HaveKey("etag"))
+		Expect(result["etag"]).To(HaveLen(16))
+		Expect(result["testField1"]).To(Equal("Test String"))
+		Expect(result["testField2"]).To(Equal(10))
+	})
+
+	It("should produce a stable hash for structs with reordered fields", func() {
+		first := ConvertStructToBSONMap(testStruct{TestField1: "Test String", TestField2: 10}, opts)
+		second := ConvertStructToBSONMap(&reorderedTestStruct{TestField1: "Test String", TestField2: 10}, opts)
+		third := NewBSONMapperStruct(reorderedTestStruct{TestField1: "Test String", TestField2: 10}).ToBSOND(opts)
+		Expect(first["etag"]).To(Equal(second["etag"]))
+		Expect(third.Map()["etag"]).To(Equal(first["etag"]))
+	})
+
+	It("should produce a different hash when the content changes", func() {
+		first := ConvertStructToBSONMap(testStruct{TestField1: "Test String", TestField2: 10}, opts)
+		second := ConvertStructToBSONMap(testStruct{TestField1: "Test String", TestField2: 11}, opts)
+		Expect(first["etag"]).NotTo(Equal(second["etag"]))
+	})
+
+	It("should return an error if the key is invalid", func() {
+		result, err := ConvertStructToBSONMapE(testStruct{}, &MappingOpts{ContentHashKey: "$etag"})
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,35 @@
+package mapper
+
+import "sync/atomic"
+
+// defaultOpts holds the defaults set by SetDefaultOpts
+var defaultOpts atomic.Value
+
+// storedOpts wraps the defaults, as an atomic.Value can't hold nil
+type storedOpts struct {
+	opts *MappingOpts
+}
+
+// SetDefaultOpts sets the options which are used whenever nil is passed as the MappingOpts,
+// allowing the options to be set once for the whole app. Passing nil clears the defaults.
+//
+// Passing explicit options overrides the defaults entirely, they aren't merged together.
+// A copy of the options is held, however any maps or functions within them are shared so
+// they shouldn't be modified once they've been set. It is safe to call concurrently with
+// any of the mapping functions
+func SetDefaultOpts(opts *MappingOpts) {
+	if opts != nil {
+		o := *opts
+		opts = &o
+	}
+	defaultOpts.Store(storedOpts{opts: opts})
+}
+
+// withDefaults returns the options, or the defaults if they're nil
+func withDefaults(opts *MappingOpts) *MappingOpts {
+	if opts != nil {
+		return opts
+	}
+	stored, _ := defaultOpts.Load().(storedOpts)
+	return stored.opts
+}
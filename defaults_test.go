@@ -0,0 +1,68 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"sync"
+)
+
+var _ = Describe("SetDefaultOpts", func() {
+	type testStruct struct {
+		ID   string `bson:"_id"`
+		Name string `bson:"name"`
+		Age  int    `bson:"age"`
+	}
+
+	AfterEach(func() {
+		SetDefaultOpts(nil)
+	})
+
+	It("should use the defaults when nil is passed as the options", func() {
+		SetDefaultOpts(&MappingOpts{RemoveID: true, GenerateFilterOrPatch: true})
+
+		Expect(ConvertStructToBSONMap(testStruct{ID: "1", Name: "Test"}, nil)).To(Equal(bson.M{"name": "Test"}))
+		Expect(NewBSONMapperStruct(testStruct{ID: "1", Name: "Test"}).ToBSOND(nil)).To(Equal(bson.D{{Key: "name", Value: "Test"}}))
+		Expect(ConvertStructToUpdateBSON(testStruct{ID: "1", Name: "Test"}, nil)).To(Equal(bson.M{"$set": bson.M{"name": "Test"}}))
+	})
+
+	It("should override the defaults entirely when explicit options are passed", func() {
+		SetDefaultOpts(&MappingOpts{RemoveID: true, GenerateFilterOrPatch: true})
+
+		result := ConvertStructToBSONMap(testStruct{ID: "1", Name: "Test"}, &MappingOpts{RemoveID: true})
+		Expect(result).To(Equal(bson.M{"name": "Test", "age": 0}))
+	})
+
+	It("should hold a copy of the defaults", func() {
+		opts := &MappingOpts{RemoveID: true}
+		SetDefaultOpts(opts)
+		opts.RemoveID = false
+
+		result := ConvertStructToBSONMap(testStruct{ID: "1", Name: "Test"}, nil)
+		Expect(result).To(Equal(bson.M{"name": "Test", "age": 0}))
+	})
+
+	It("should map with no options once the defaults are cleared", func() {
+		SetDefaultOpts(&MappingOpts{RemoveID: true})
+		SetDefaultOpts(nil)
+
+		result := ConvertStructToBSONMap(testStruct{ID: "1", Name: "Test"}, nil)
+		Expect(result).To(Equal(bson.M{"_id": "1", "name": "Test", "age": 0}))
+	})
+
+	It("should be safe to set the defaults while mapping", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				SetDefaultOpts(&MappingOpts{RemoveID: true})
+			}()
+			go func() {
+				defer wg.Done()
+				ConvertStructToBSONMap(testStruct{ID: "1", Name: "Test"}, nil)
+			}()
+		}
+		wg.Wait()
+	})
+})
@@ -0,0 +1,76 @@
+package mapper
+
+import "reflect"
+
+// Descriptions returns the description of each field which has one, keyed by the key the field would
+// be stored under. The descriptions are read from the DescriptionTagName tag (`description` by default)
+// and can be used alongside InferBSONTypes when generating schema documentation, ie.
+//
+// 	 type User struct {
+// 	 	 FirstName string `bson:"firstName" description:"The user's given name"`
+// 	 }
+//
+// 	 // map[string]string{ "firstName": "The user's given name" }
+//
+// The fields of anonymous embedded structs are promoted in the same way as they are when mapping,
+// otherwise only the fields of the top level struct are reported.
+//
+// Returns nil if no fields have a description
+func Descriptions(s interface{}, opts *MappingOpts) map[string]string {
+	out, _ := DescriptionsE(s, opts)
+	return out
+}
+
+// DescriptionsE behaves the same as Descriptions, however it returns
+// an error if it isn't passed a struct
+func DescriptionsE(s interface{}, opts *MappingOpts) (map[string]string, error) {
+	st, err := NewBSONMapperStructE(s)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = withDefaults(opts)
+	tag := DefaultDescriptionTagName
+	if opts != nil && opts.DescriptionTagName != "" {
+		tag = opts.DescriptionTagName
+	}
+
+	out := make(map[string]string)
+	st.descriptions(tag, opts, out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// descriptions adds the description of each of the struct's fields to the map, explicit
+// fields take precedence over any promoted from embedded structs
+func (s *StructToBSON) descriptions(tag string, opts *MappingOpts, out map[string]string) {
+	for _, field := range s.structFields(opts) {
+		tagName, _ := parseTag(s.fieldTag(field, opts))
+
+		t := field.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if field.Anonymous && tagName == "" && t.Kind() == reflect.Struct && (opts == nil || !opts.EmbeddedAsSubdocument) {
+			promoted := make(map[string]string)
+			s.nestedStruct(reflect.New(t).Elem(), opts).descriptions(tag, opts, promoted)
+			for k, v := range promoted {
+				if _, ok := out[k]; !ok {
+					out[k] = v
+				}
+			}
+			continue
+		}
+
+		desc := field.Tag.Get(tag)
+		if desc == "" {
+			continue
+		}
+		if key, _, ok := s.fieldKey(field, opts); ok {
+			out[key] = desc
+		}
+	}
+}
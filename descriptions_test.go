@@ -0,0 +1,62 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Descriptions", func() {
+	type Base struct {
+		CreatedAt string `bson:"createdAt" description:"When the document was created"`
+	}
+
+	type user struct {
+		Base
+		FirstName string `bson:"firstName" description:"The user's given name"`
+		LastName  string `bson:"lastName"`
+		Age       int    `description:"The user's age in years" doc:"Age"`
+		Ignored   string `bson:"-" description:"Never stored"`
+	}
+
+	It("should return the description of each annotated field by its key", func() {
+		Expect(Descriptions(user{}, nil)).To(Equal(map[string]string{
+			"createdAt": "When the document was created",
+			"firstName": "The user's given name",
+			"Age":       "The user's age in years",
+		}))
+	})
+
+	It("should read the descriptions from the DescriptionTagName tag", func() {
+		Expect(Descriptions(&user{}, &MappingOpts{DescriptionTagName: "doc"})).To(Equal(map[string]string{
+			"Age": "Age",
+		}))
+	})
+
+	It("should resolve the keys using the mapping options", func() {
+		result := Descriptions(user{}, &MappingOpts{
+			DefaultKeyCase: KeyCaseLowerCamel,
+			RenameKeys:     map[string]string{"firstName": "givenName"},
+		})
+
+		Expect(result).To(HaveKeyWithValue("givenName", "The user's given name"))
+		Expect(result).To(HaveKeyWithValue("age", "The user's age in years"))
+	})
+
+	It("should return nil if no fields have a description", func() {
+		type plain struct {
+			Name string `bson:"name"`
+		}
+
+		Expect(Descriptions(plain{}, nil)).To(BeNil())
+	})
+
+	It("should return an error from the error API if it isn't passed a struct", func() {
+		_, err := DescriptionsE("not a struct", nil)
+		Expect(err).To(HaveOccurred())
+
+		var nilUser *user
+		_, err = DescriptionsE(nilUser, nil)
+		Expect(errors.Is(err, ErrNilStruct)).To(BeTrue())
+	})
+})
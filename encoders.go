@@ -0,0 +1,34 @@
+package mapper
+
+import (
+	"fmt"
+	"sync"
+)
+
+// namedEncoders holds the encoders registered by RegisterNamedEncoder, keyed by their name
+var namedEncoders sync.Map
+
+// RegisterNamedEncoder registers an encoder under the name, which the fields with the "encoder=name" tag option
+// are passed through. The encoder is called with the value held by the field and returns the value which is stored
+// in it's place, ie. to store a custom type in a format the Mongo-Go Driver understands.
+//
+// Registering an encoder under a name which is already registered replaces it, and passing a nil function
+// removes it. It is safe to call concurrently with any of the mapping functions
+func RegisterNamedEncoder(name string, fn func(interface{}) interface{}) {
+	if fn == nil {
+		namedEncoders.Delete(name)
+		return
+	}
+	namedEncoders.Store(name, fn)
+}
+
+// namedEncoder returns the encoder registered under the name
+//
+// Returns an error wrapping ErrUnknownEncoder if one hasn't been registered
+func namedEncoder(name string) (func(interface{}) interface{}, error) {
+	fn, ok := namedEncoders.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, ErrUnknownEncoder)
+	}
+	return fn.(func(interface{}) interface{}), nil
+}
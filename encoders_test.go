@@ -0,0 +1,57 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+var _ = Describe("RegisterNamedEncoder", func() {
+	type testStruct struct {
+		Name  string  `bson:"name,encoder=upper"`
+		Price float64 `bson:"price,encoder=cents"`
+		Tags  []int   `bson:"tags,encoder=upper,omitempty"`
+		Other string  `bson:"other"`
+	}
+
+	BeforeEach(func() {
+		RegisterNamedEncoder("upper", func(v interface{}) interface{} {
+			if s, ok := v.(string); ok {
+				return strings.ToUpper(s)
+			}
+			return v
+		})
+		RegisterNamedEncoder("cents", func(v interface{}) interface{} {
+			return int64(v.(float64) * 100)
+		})
+	})
+
+	AfterEach(func() {
+		RegisterNamedEncoder("upper", nil)
+		RegisterNamedEncoder("cents", nil)
+	})
+
+	It("should store the value returned by the encoder referenced in the tag", func() {
+		result := ConvertStructToBSONMap(testStruct{Name: "test", Price: 1.5, Other: "test"}, nil)
+		Expect(result).To(Equal(bson.M{"name": "TEST", "price": int64(150), "other": "test"}))
+	})
+
+	It("should replace an encoder registered under the same name", func() {
+		RegisterNamedEncoder("upper", func(v interface{}) interface{} { return "replaced" })
+
+		result := ConvertStructToBSONMap(testStruct{Name: "test", Tags: []int{1}}, nil)
+		Expect(result).To(HaveKeyWithValue("name", "replaced"))
+		Expect(result).To(HaveKeyWithValue("tags", "replaced"))
+	})
+
+	It("should return an error if the encoder hasn't been registered", func() {
+		RegisterNamedEncoder("cents", nil)
+
+		result, err := ConvertStructToBSONMapE(testStruct{Name: "test"}, nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrUnknownEncoder)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring(`"price"`)))
+	})
+})
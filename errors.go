@@ -0,0 +1,72 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotAStruct is returned when the value being mapped isn't a struct or pointer to a struct
+	ErrNotAStruct = errors.New("not a struct")
+
+	// ErrNilStruct is returned when the value being mapped is a nil pointer to a struct,
+	// it wraps ErrNotAStruct as there is no struct to map
+	ErrNilStruct = fmt.Errorf("nil pointer to struct: %w", ErrNotAStruct)
+
+	// ErrInvalidKey is returned when a resolved key can't be safely stored in a MongoDB document
+	ErrInvalidKey = errors.New("invalid key")
+
+	// ErrNotEncodable is returned when a value can't be encoded by the Mongo-Go Driver
+	ErrNotEncodable = errors.New("value can't be encoded")
+
+	// ErrStringTooLong is returned when a string value is longer than the MaxStringLen
+	ErrStringTooLong = errors.New("string too long")
+
+	// ErrInvalidUpdateOperator is returned when a field can't be routed into it's update operator
+	ErrInvalidUpdateOperator = errors.New("invalid update operator")
+
+	// ErrMissingRequired is returned when a field with the "required" tag option holds a zero value
+	ErrMissingRequired = errors.New("missing required field")
+
+	// ErrMissingKeyField is returned when an element of a slice with the "keyby=field"
+	// tag option doesn't hold the field it should be keyed by
+	ErrMissingKeyField = errors.New("missing key field")
+
+	// ErrInvalidObjectID is returned when a field with the "objectid" tag option can't be converted to an ObjectID
+	ErrInvalidObjectID = errors.New("invalid ObjectID")
+
+	// ErrInvalidLengthField is returned when a field with the "len=field" tag option can't
+	// hold the length of the field, or the field doesn't exist or has no length
+	ErrInvalidLengthField = errors.New("invalid length field")
+
+	// ErrInvalidFieldPath is returned when a field path doesn't lead to a nested struct
+	ErrInvalidFieldPath = errors.New("invalid field path")
+
+	// ErrNotOneOf is returned when a field with the "oneof=a b c" tag option doesn't hold one of the allowed values
+	ErrNotOneOf = errors.New("value not allowed")
+
+	// ErrInvalidNumber is returned when a field with the "numify" tag option doesn't hold a valid number
+	// and StrictNumify is set
+	ErrInvalidNumber = errors.New("invalid number")
+
+	// ErrInvalidPrecision is returned when the number of decimal places in the "round=N" tag option isn't valid
+	ErrInvalidPrecision = errors.New("invalid precision")
+
+	// ErrInvalidRef is returned when a field with the "ref=collection" tag option doesn't name a collection
+	ErrInvalidRef = errors.New("invalid reference")
+
+	// ErrUnsupportedHash is returned when the algorithm in the "hash=algo" tag option isn't supported
+	ErrUnsupportedHash = errors.New("unsupported hash algorithm")
+
+	// ErrUnknownEncoder is returned when the "encoder=name" tag option references an encoder which hasn't been registered
+	ErrUnknownEncoder = errors.New("unknown encoder")
+
+	// ErrCannotAssign is returned when a value held in a map can't be assigned to it's struct field
+	ErrCannotAssign = errors.New("value can't be assigned")
+
+	// ErrPanic is returned by SafeConvert when it recovers from a panic while mapping
+	ErrPanic = errors.New("panic")
+
+	// ErrForbiddenKind is returned when a field holds one of the ForbiddenKinds
+	ErrForbiddenKind = errors.New("forbidden kind")
+)
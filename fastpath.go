@@ -0,0 +1,120 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"sync"
+)
+
+// scalarPlans caches the plan for mapping each struct type (and tag name) which is made up of
+// only scalar fields, or nil if the struct isn't eligible for the fast path
+var scalarPlans sync.Map
+
+// scalarPlanKey is the key a plan is cached under, as the tags which are parsed depend on the tag name
+type scalarPlanKey struct {
+	t       reflect.Type
+	tagName string
+}
+
+// scalarField is a field of a struct which is eligible for the fast path, along with it's unresolved key
+type scalarField struct {
+	index int
+	name  string
+}
+
+// scalarDoc is a fast path for generating a filter from a flat struct of scalars (the common case),
+// which writes the values directly rather than going through the general mapping logic. It returns
+// false if the struct or options aren't eligible, in which case the general mapping logic should be used.
+//
+// The struct is only eligible if every field is a bool, number or string (which doesn't implement bson.Marshaler)
+// with no tag options other than "omitempty" (and isn't the "_id"), and none of the options which alter scalar
+// values or keys are set
+func (s *StructToBSON) scalarDoc(opts *MappingOpts) (bson.D, bool, error) {
+	if s.skipped != nil || s.fieldNames != nil || opts == nil || !opts.GenerateFilterOrPatch || len(opts.RenameKeys) > 0 || opts.EmptyStringAsNull ||
+		opts.KeepEmptyStrings || opts.MaxStringLen > 0 || opts.UseJSONMarshaler || opts.DefaultKeyCase != KeyCaseUnchanged || len(opts.ForbiddenKinds) > 0 || opts.FallbackTagName != "" {
+		return nil, false, nil
+	}
+
+	plan := s.scalarPlan()
+	if plan == nil {
+		return nil, false, nil
+	}
+
+	out := make(bson.D, 0, len(plan))
+	for _, f := range plan {
+		val := s.value.Field(f.index)
+		if val.IsZero() {
+			continue
+		}
+
+		key, err := resolveKey(f.name, opts)
+		if err != nil {
+			return nil, true, err
+		}
+		out = setElem(out, key, val.Interface())
+	}
+
+	if len(out) == 0 {
+		return nil, true, nil
+	}
+	return out, true, nil
+}
+
+// scalarPlan returns the cached plan for mapping the struct, or nil if it isn't eligible for the fast path
+func (s *StructToBSON) scalarPlan() []scalarField {
+	key := scalarPlanKey{t: s.value.Type(), tagName: s.TagName}
+	if plan, ok := scalarPlans.Load(key); ok {
+		return plan.([]scalarField)
+	}
+
+	plan := buildScalarPlan(key.t, key.tagName)
+	scalarPlans.Store(key, plan)
+	return plan
+}
+
+// buildScalarPlan works out the plan for mapping the struct type, or nil if it isn't eligible for the fast path
+func buildScalarPlan(t reflect.Type, tagName string) []scalarField {
+	plan := make([]scalarField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get(tagName)
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		if field.Anonymous || !isScalarKind(field.Type.Kind()) || implementsMarshaler(field.Type) {
+			return nil
+		}
+
+		name, tagOpts := parseTag(tag)
+		if name == "_id" {
+			return nil
+		}
+		for opt := range tagOpts {
+			if opt != "omitempty" {
+				return nil
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		plan = append(plan, scalarField{index: i, name: name})
+	}
+
+	if len(plan) == 0 {
+		return nil
+	}
+	return plan
+}
+
+// isScalarKind checks whether the kind is a bool, number or string
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
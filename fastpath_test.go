@@ -0,0 +1,96 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"strings"
+	"testing"
+)
+
+type scalarFilterStruct struct {
+	FirstName string  `bson:"firstName"`
+	LastName  string  `bson:"lastName,omitempty"`
+	Age       int     `bson:"age"`
+	Score     float64 `bson:"score"`
+	Active    bool    `bson:"active"`
+	Level     uint8
+	internal  string
+	Ignored   string `bson:"-"`
+}
+
+var _ = Describe("The scalar fast path", func() {
+	filter := &MappingOpts{GenerateFilterOrPatch: true}
+
+	DescribeTable("should produce the same document as the general mapping logic",
+		func(s interface{}, opts *MappingOpts) {
+			m := NewBSONMapperStruct(s)
+			expected, expectedErr := m.mapFields(opts)
+
+			result, ok, err := m.scalarDoc(opts)
+			Expect(ok).To(BeTrue())
+			Expect(result).To(Equal(expected))
+			if expectedErr == nil {
+				Expect(err).To(BeNil())
+			} else {
+				Expect(err).To(MatchError(expectedErr.Error()))
+			}
+		},
+		Entry("when every field is set", scalarFilterStruct{FirstName: "Jane", LastName: "Doe", Age: 30, Score: 1.5, Active: true, Level: 2, internal: "a", Ignored: "b"}, filter),
+		Entry("when some of the fields are zero", scalarFilterStruct{FirstName: "Jane", Active: true}, filter),
+		Entry("when every field is zero", scalarFilterStruct{}, filter),
+		Entry("when the keys are sanitized", scalarFilterStruct{FirstName: "Jane"}, &MappingOpts{
+			GenerateFilterOrPatch: true,
+			KeySanitizer:          func(key string) (string, error) { return strings.ToUpper(key), nil },
+		}),
+		Entry("when a key is too long", scalarFilterStruct{FirstName: "Jane"}, &MappingOpts{GenerateFilterOrPatch: true, MaxKeyLength: 3}),
+		Entry("when multiple fields share a key", struct {
+			First  string `bson:"name"`
+			Second string `bson:"name"`
+		}{First: "First", Second: "Second"}, filter),
+	)
+
+	It("should return the error from the error API if a key is invalid", func() {
+		result, err := ConvertStructToBSONMapE(scalarFilterStruct{FirstName: "Jane"}, &MappingOpts{GenerateFilterOrPatch: true, MaxKeyLength: 3})
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+	})
+
+	DescribeTable("should fall back to the general mapping logic",
+		func(s interface{}, opts *MappingOpts) {
+			_, ok, _ := NewBSONMapperStruct(s).scalarDoc(opts)
+			Expect(ok).To(BeFalse())
+		},
+		Entry("when not generating a filter", scalarFilterStruct{}, nil),
+		Entry("when the options alter the values", scalarFilterStruct{}, &MappingOpts{GenerateFilterOrPatch: true, EmptyStringAsNull: true}),
+		Entry("when the keys are renamed", scalarFilterStruct{}, &MappingOpts{GenerateFilterOrPatch: true, RenameKeys: map[string]string{"age": "years"}}),
+		Entry("when a field isn't a scalar", benchmarkStruct{}, filter),
+		Entry("when a field has other tag options", struct {
+			Name string `bson:"name,trim"`
+		}{}, filter),
+		Entry("when a field is the _id", struct {
+			ID string `bson:"_id"`
+		}{}, filter),
+	)
+})
+
+func BenchmarkScalarFilter(b *testing.B) {
+	s := scalarFilterStruct{FirstName: "Jane", Age: 30, Active: true}
+	opts := &MappingOpts{GenerateFilterOrPatch: true}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = NewBSONMapperStruct(s).toBSONDoc(opts)
+	}
+}
+
+func BenchmarkScalarFilterGeneral(b *testing.B) {
+	s := scalarFilterStruct{FirstName: "Jane", Age: 30, Active: true}
+	opts := &MappingOpts{GenerateFilterOrPatch: true}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = NewBSONMapperStruct(s).mapFields(opts)
+	}
+}
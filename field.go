@@ -0,0 +1,50 @@
+package mapper
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"strings"
+)
+
+// ConvertFieldToBSONMap navigates to the nested struct at the dot separated field path and maps
+// just that struct, the same as it would be mapped within the parent. Each field along the path
+// can be referenced by either it's name or tag name, ie. "Profile.address" or "profile.address"
+//
+// This avoids mapping the whole parent when only one section of it is being updated.
+//
+// Returns nil if the path doesn't lead to a struct, or if a pointer along the path is nil
+func ConvertFieldToBSONMap(s interface{}, fieldPath string, opts *MappingOpts) bson.M {
+	out, _ := ConvertFieldToBSONMapE(s, fieldPath, opts)
+	return out
+}
+
+// ConvertFieldToBSONMapE behaves the same as ConvertFieldToBSONMap, however it returns an
+// error if the path doesn't lead to a struct or the struct can't be mapped. A nil pointer
+// along the path isn't an error, as there is nothing to map
+func ConvertFieldToBSONMapE(s interface{}, fieldPath string, opts *MappingOpts) (bson.M, error) {
+	if err := checkStruct(s); err != nil {
+		return nil, err
+	}
+
+	opts = withDefaults(opts)
+	n := NewBSONMapperStruct(s)
+	for _, ref := range strings.Split(fieldPath, ".") {
+		val, ok := n.fieldByRef(ref, opts)
+		if !ok {
+			return nil, fmt.Errorf("no field named %q within %q: %w", ref, fieldPath, ErrInvalidFieldPath)
+		}
+
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return nil, nil
+			}
+			val = val.Elem()
+		}
+		if val.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field %q within %q of type %s isn't a struct: %w", ref, fieldPath, val.Type(), ErrInvalidFieldPath)
+		}
+		n = n.nestedStruct(val, opts)
+	}
+	return n.toBSONMap(opts)
+}
@@ -0,0 +1,66 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var _ = Describe("ConvertFieldToBSONMap", func() {
+	type address struct {
+		Street string `bson:"street"`
+		City   string `bson:"city,omitempty"`
+	}
+
+	type profile struct {
+		Bio     string   `bson:"bio"`
+		Address *address `bson:"address"`
+	}
+
+	type user struct {
+		Name    string  `bson:"name"`
+		Profile profile `bson:"profile"`
+	}
+
+	testUser := user{
+		Name: "Test User",
+		Profile: profile{
+			Bio:     "Test Bio",
+			Address: &address{Street: "1 Test Street"},
+		},
+	}
+
+	It("should map just the nested struct at the path", func() {
+		result := ConvertFieldToBSONMap(testUser, "profile", nil)
+		Expect(result).To(Equal(bson.M{"bio": "Test Bio", "address": bson.M{"street": "1 Test Street"}}))
+	})
+
+	It("should navigate through pointers using field or tag names", func() {
+		Expect(ConvertFieldToBSONMap(&testUser, "Profile.address", nil)).To(Equal(bson.M{"street": "1 Test Street"}))
+		Expect(ConvertFieldToBSONMap(testUser, "profile.Address", nil)).To(Equal(bson.M{"street": "1 Test Street"}))
+	})
+
+	It("should factor in the mapping options", func() {
+		result := ConvertFieldToBSONMap(user{Profile: profile{Bio: "Test Bio"}}, "profile", &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(result).To(Equal(bson.M{"bio": "Test Bio"}))
+	})
+
+	It("should return nil if a pointer along the path is nil", func() {
+		result, err := ConvertFieldToBSONMapE(user{}, "profile.address", nil)
+		Expect(result).To(BeNil())
+		Expect(err).To(BeNil())
+	})
+
+	It("should return an error from the error API if the field doesn't exist", func() {
+		result, err := ConvertFieldToBSONMapE(testUser, "profile.missing", nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrInvalidFieldPath)).To(BeTrue())
+	})
+
+	It("should return an error from the error API if the field isn't a struct", func() {
+		result, err := ConvertFieldToBSONMapE(testUser, "name", nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrInvalidFieldPath)).To(BeTrue())
+	})
+})
@@ -0,0 +1,148 @@
+//go:build go1.18
+// +build go1.18
+
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The tag options which the fuzz targets combine with the fuzzed input
+var fuzzTagOptions = []string{
+	"omitempty", "omitnested", "flatten", "inline", "string", "stringkey=s", "json", "timestamp",
+	"objectid", "hex", "base64", "unwrap", "keyby=name", "tolist=name", "sparse", "minsize",
+	"len=f0", "trim", "lower", "upper", "char", "hash=sha256", "group=g", "resettags", "unset", "inc", "bit=and", "currentdate", "shardkey", "immutable", "version",
+}
+
+// The field types which the fuzzed structs are built from
+var fuzzFieldTypes = []reflect.Type{
+	reflect.TypeOf(""),
+	reflect.TypeOf(0),
+	reflect.TypeOf(int64(0)),
+	reflect.TypeOf(false),
+	reflect.TypeOf([]byte(nil)),
+	reflect.TypeOf(time.Time{}),
+	reflect.TypeOf((*time.Time)(nil)),
+	reflect.TypeOf(primitive.ObjectID{}),
+	reflect.TypeOf([]string(nil)),
+	reflect.TypeOf(map[string]interface{}(nil)),
+	reflect.TypeOf((*interface{})(nil)).Elem(),
+	reflect.TypeOf(testMoney{}),
+	reflect.TypeOf(&testMoney{}),
+	reflect.TypeOf([]testMoney(nil)),
+	reflect.TypeOf([]*testMoney(nil)),
+	reflect.TypeOf(map[string]testMoney(nil)),
+}
+
+func FuzzParseTag(f *testing.F) {
+	for _, seed := range []string{
+		"", ",", "name", "name,omitempty", ",omitempty", "name,when=", "name,=value",
+		"name, omitempty ,,minsize", "name,keyby=id,tolist=key", "-", "$name,inline",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, tag string) {
+		name, opts := parseTag(tag)
+		if strings.Contains(name, ",") {
+			t.Fatalf("the name %q parsed from %q contains a \",\"", name, tag)
+		}
+		for opt := range opts {
+			if opt == "" || opt != strings.TrimSpace(opt) {
+				t.Fatalf("the option %q parsed from %q isn't trimmed", opt, tag)
+			}
+			opts.Has(opt)
+			opts.Value(opt)
+		}
+	})
+}
+
+func FuzzConvert(f *testing.F) {
+	f.Add("name", []byte{0, 1, 2}, []byte{0, 0, 0}, "value", true)
+	f.Add("", []byte{11, 12, 13, 14}, []byte{2, 3, 4, 5}, "", false)
+	f.Add("_id", []byte{7, 8, 9}, []byte{8, 9, 10}, "5f0c1a2b3c4d5e6f7a8b9c0d", true)
+	f.Add("$key.path", []byte{15, 10, 6}, []byte{1, 14, 13}, "a.b", false)
+
+	f.Fuzz(func(t *testing.T, name string, types []byte, opts []byte, str string, filter bool) {
+		if len(types) > 8 {
+			types = types[:8]
+		}
+
+		// Build a struct with a field of each of the fuzzed types, tagged
+		// with the fuzzed name and one of the fuzzed tag options
+		fields := make([]reflect.StructField, 0, len(types))
+		for i, b := range types {
+			tag := name
+			if i > 0 {
+				tag = "f" + string(rune('0'+i))
+			}
+			if i < len(opts) {
+				tag += "," + fuzzTagOptions[int(opts[i])%len(fuzzTagOptions)]
+			}
+			fields = append(fields, reflect.StructField{
+				Name: "F" + string(rune('A'+i)),
+				Type: fuzzFieldTypes[int(b)%len(fuzzFieldTypes)],
+				Tag:  reflect.StructTag(`bson:"` + strings.NewReplacer(`"`, "", "`", "", `\`, "").Replace(tag) + `"`),
+			})
+		}
+
+		v := reflect.New(reflect.StructOf(fields)).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			fuzzValue(v.Field(i), str)
+		}
+
+		s := v.Interface()
+		mappingOpts := &MappingOpts{GenerateFilterOrPatch: filter, ValidateEncodable: true, ContentHashKey: "hash"}
+		ConvertStructToBSONMapE(s, mappingOpts)
+		ConvertStructToBSONMapE(s, nil)
+		NewBSONMapperStruct(s).ToBSONDE(mappingOpts)
+		ConvertStructToUpdateBSONE(s, mappingOpts)
+		ConvertStructToUpdatePipelineE(s, mappingOpts)
+		FlattenToPairsE(s, mappingOpts)
+		SplitMutableImmutableE(s, mappingOpts)
+		InferBSONTypesE(s, mappingOpts)
+	})
+}
+
+// fuzzValue fills the value with data derived from the fuzzed string
+func fuzzValue(v reflect.Value, str string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(str)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(int64(len(str)))
+	case reflect.Bool:
+		v.SetBool(len(str)%2 == 0)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(str))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), 2, 2)
+		fuzzValue(s.Index(0), str)
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		fuzzValue(elem, str)
+		m.SetMapIndex(reflect.ValueOf(str), elem)
+		v.Set(m)
+	case reflect.Ptr:
+		if len(str)%3 != 0 {
+			p := reflect.New(v.Type().Elem())
+			fuzzValue(p.Elem(), str)
+			v.Set(p)
+		}
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(str))
+	case reflect.Struct:
+		if m, ok := v.Addr().Interface().(*testMoney); ok {
+			m.Currency = str
+			m.Pence = int64(len(str))
+		}
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/naamancurtis/mongo-go-struct-to-bson/mapper
 
-go 1.12
+go 1.13
 
 require (
 	github.com/onsi/ginkgo v1.14.2
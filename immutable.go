@@ -0,0 +1,53 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SplitMutableImmutable maps the struct (factoring in any options passed) and splits the result
+// into two maps, one holding the fields with the "immutable" tag option and one holding the rest.
+//
+// This is intended for change-tracking, ie. only setting the mutable fields in an update while
+// using the immutable fields as constraints. Either map is nil if it would be empty
+func SplitMutableImmutable(s interface{}, opts *MappingOpts) (mutable, immutable bson.M) {
+	mutable, immutable, _ = SplitMutableImmutableE(s, opts)
+	return mutable, immutable
+}
+
+// SplitMutableImmutableE behaves the same as SplitMutableImmutable, however it
+// returns an error if the struct can't be mapped
+func SplitMutableImmutableE(s interface{}, opts *MappingOpts) (mutable, immutable bson.M, err error) {
+	if err := checkStruct(s); err != nil {
+		return nil, nil, err
+	}
+
+	opts = withDefaults(opts)
+	m := NewBSONMapperStruct(s)
+	doc, err := m.topLevelDoc(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	immutableKeys := make(map[string]bool)
+	m.eachFieldKey(opts, func(key string, tagOpts tagOptions) {
+		if tagOpts.Has("immutable") {
+			immutableKeys[key] = true
+		}
+	})
+
+	for _, e := range doc {
+		if immutableKeys[e.Key] {
+			if immutable == nil {
+				immutable = bson.M{}
+			}
+			immutable[e.Key] = e.Value
+			continue
+		}
+
+		if mutable == nil {
+			mutable = bson.M{}
+		}
+		mutable[e.Key] = e.Value
+	}
+	return mutable, immutable, nil
+}
@@ -0,0 +1,60 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+var _ = Describe("SplitMutableImmutable", func() {
+	type testStruct struct {
+		ID        string    `bson:"_id,immutable"`
+		CreatedAt time.Time `bson:"createdAt,immutable"`
+		Name      string    `bson:"name"`
+		Age       int       `bson:"age,omitempty"`
+	}
+
+	testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	It("should split the fields by the immutable tag option", func() {
+		mutable, immutable := SplitMutableImmutable(
+			testStruct{ID: "Test ID", CreatedAt: testTime, Name: "Test", Age: 30}, nil,
+		)
+		Expect(mutable).To(Equal(bson.M{"name": "Test", "age": 30}))
+		Expect(immutable).To(Equal(bson.M{"_id": "Test ID", "createdAt": testTime}))
+	})
+
+	It("should factor in the mapping options", func() {
+		mutable, immutable := SplitMutableImmutable(
+			testStruct{ID: "Test ID", Name: "Test"},
+			&MappingOpts{GenerateFilterOrPatch: true, RenameKeys: map[string]string{"_id": "ref"}},
+		)
+		Expect(mutable).To(Equal(bson.M{"name": "Test"}))
+		Expect(immutable).To(Equal(bson.M{"ref": "Test ID"}))
+	})
+
+	It("should return nil for either map if it would be empty", func() {
+		mutable, immutable := SplitMutableImmutable(
+			testStruct{ID: "Test ID", CreatedAt: testTime},
+			&MappingOpts{GenerateFilterOrPatch: true},
+		)
+		Expect(mutable).To(BeNil())
+		Expect(immutable).To(Equal(bson.M{"_id": "Test ID", "createdAt": testTime}))
+
+		mutable, immutable = SplitMutableImmutable(
+			struct {
+				Name string `bson:"name"`
+			}{Name: "Test"}, nil,
+		)
+		Expect(mutable).To(Equal(bson.M{"name": "Test"}))
+		Expect(immutable).To(BeNil())
+	})
+
+	It("should return an error from the error API if it isn't passed a struct", func() {
+		mutable, immutable, err := SplitMutableImmutableE("Test String", nil)
+		Expect(mutable).To(BeNil())
+		Expect(immutable).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,80 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+)
+
+// jsonMarshaler returns the value as a json.Marshaler, if it should be
+// converted to it's JSON representation rather than being mapped
+//
+// Unless the field has the "json" tag option, types which the Mongo-Go Driver
+// has dedicated support for (ie. time.Time) are left as they are
+func jsonMarshaler(val reflect.Value, tagOpts tagOptions, opts *MappingOpts) (json.Marshaler, bool) {
+	tagged := tagOpts.Has("json")
+	if !tagged && (opts == nil || !opts.UseJSONMarshaler) {
+		return nil, false
+	}
+
+	i := interfaceOf(val)
+	m, ok := i.(json.Marshaler)
+	if !ok || (val.Kind() == reflect.Ptr && val.IsNil()) {
+		return nil, false
+	}
+	return m, tagged || !isDriverType(i)
+}
+
+// jsonValue calls MarshalJSON on the value and decodes the result, JSON objects
+// are converted to a bson.M and JSON numbers to either an int64 or float64
+func jsonValue(m json.Marshaler, opts *MappingOpts) (interface{}, error) {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return nil, err
+	}
+	return fromJSON(val, opts)
+}
+
+// fromJSON recursively converts a decoded JSON value into the types
+// which should be stored in the bson.M, resolving any object keys
+func fromJSON(val interface{}, opts *MappingOpts) (interface{}, error) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		m := make(bson.M, len(v))
+		for k, e := range v {
+			key, err := resolveMapKey(k, opts)
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = fromJSON(e, opts); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+
+	case []interface{}:
+		for i := range v {
+			var err error
+			if v[i], err = fromJSON(v[i], opts); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	}
+	return val, nil
+}
@@ -0,0 +1,112 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+// A type whose JSON representation has a different shape to it's fields
+type testMoney struct {
+	Pence    int64  `bson:"pence"`
+	Currency string `bson:"currency"`
+}
+
+func (m testMoney) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"amount": %d.%02d, "currency": {"code": %q}, "tags": [1, "a"]}`, m.Pence/100, m.Pence%100, m.Currency)), nil
+}
+
+// A type whose JSON representation is a scalar
+type testLevel int
+
+func (l testLevel) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"level-%d"`, int(l))), nil
+}
+
+// A type which fails to marshal
+type testBrokenJSON struct{}
+
+func (testBrokenJSON) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("broken")
+}
+
+var _ = Describe("json.Marshaler conversion", func() {
+	money := testMoney{Pence: 1234, Currency: "GBP"}
+	expectedMoney := bson.M{
+		"amount":   12.34,
+		"currency": bson.M{"code": "GBP"},
+		"tags":     []interface{}{int64(1), "a"},
+	}
+
+	It("should store the decoded JSON for fields with the json tag option", func() {
+		result := ConvertStructToBSONMap(
+			struct {
+				Price testMoney `bson:"price,json"`
+				Level testLevel `bson:"level,json"`
+			}{
+				Price: money,
+				Level: 3,
+			}, nil,
+		)
+		Expect(result).To(Equal(bson.M{"price": expectedMoney, "level": "level-3"}))
+	})
+
+	It("should map fields without the json tag option as usual", func() {
+		result := ConvertStructToBSONMap(
+			struct {
+				Price testMoney `bson:"price"`
+			}{
+				Price: money,
+			}, nil,
+		)
+		Expect(result).To(Equal(bson.M{"price": bson.M{"pence": int64(1234), "currency": "GBP"}}))
+	})
+
+	It("should store the decoded JSON for every json.Marshaler when UseJSONMarshaler is set", func() {
+		testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		result := ConvertStructToBSONMap(
+			struct {
+				Price *testMoney `bson:"price"`
+				Time  time.Time  `bson:"time"`
+			}{
+				Price: &money,
+				Time:  testTime,
+			},
+			&MappingOpts{UseJSONMarshaler: true},
+		)
+		Expect(result).To(Equal(bson.M{"price": expectedMoney, "time": testTime}))
+	})
+
+	It("should pull the decoded JSON up a level with the inline tag option", func() {
+		result := ConvertStructToBSONMap(
+			struct {
+				Price testMoney `bson:"price,json,inline"`
+			}{
+				Price: money,
+			}, nil,
+		)
+		Expect(result).To(Equal(expectedMoney))
+	})
+
+	It("should leave nil pointers as they are", func() {
+		result := ConvertStructToBSONMap(
+			struct {
+				Price *testMoney `bson:"price,json"`
+			}{}, nil,
+		)
+		Expect(result).To(Equal(bson.M{"price": (*testMoney)(nil)}))
+	})
+
+	It("should return an error from the error API if MarshalJSON fails", func() {
+		result, err := ConvertStructToBSONMapE(
+			struct {
+				Broken testBrokenJSON `bson:"broken,json"`
+			}{}, nil,
+		)
+		Expect(result).To(BeNil())
+		Expect(err).To(MatchError(ContainSubstring(`"broken"`)))
+	})
+})
@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// KeyCase is the case the keys of fields without a tag name are converted to (see DefaultKeyCase)
+type KeyCase int
+
+const (
+	// KeyCaseUnchanged uses the field's name as it is, ie. "LastActive"
+	KeyCaseUnchanged KeyCase = iota
+
+	// KeyCaseLowerCamel lower-cases the leading capitals of the field's name, ie. "lastActive" or "httpServer"
+	KeyCaseLowerCamel
+
+	// KeyCaseLower lower-cases the whole of the field's name, ie. "lastactive"
+	KeyCaseLower
+)
+
+// defaultKeyCase converts the key of a field without a tag name into the DefaultKeyCase
+func (opts *MappingOpts) defaultKeyCase(key string) string {
+	if opts == nil || opts.DefaultKeyCase == KeyCaseUnchanged {
+		return key
+	}
+	return opts.DefaultKeyCase.apply(key)
+}
+
+// apply converts the key into the case, applying it to each segment of a dot separated key
+func (c KeyCase) apply(key string) string {
+	segments := strings.Split(key, ".")
+	for i, segment := range segments {
+		switch c {
+		case KeyCaseLowerCamel:
+			segments[i] = lowerCamel(segment)
+		case KeyCaseLower:
+			segments[i] = strings.ToLower(segment)
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// lowerCamel lower-cases the leading capitals of the string, keeping the last of them if it starts
+// the next word, ie. "LastActive" -> "lastActive", "HTTPServer" -> "httpServer" and "ID" -> "id"
+func lowerCamel(s string) string {
+	r := []rune(s)
+
+	n := 0
+	for n < len(r) && unicode.IsUpper(r[n]) {
+		n++
+	}
+	if n > 1 && n < len(r) && unicode.IsLower(r[n]) {
+		n--
+	}
+
+	for i := 0; i < n; i++ {
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
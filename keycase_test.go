@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+var _ = Describe("DefaultKeyCase", func() {
+	type metadata struct {
+		LastActive time.Time
+		Tags       []string `bson:"Tags"`
+	}
+
+	type user struct {
+		ID       string `bson:"_id"`
+		UserName string
+		Metadata metadata
+	}
+
+	testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	testUser := user{ID: "1", UserName: "Test", Metadata: metadata{LastActive: testTime, Tags: []string{"a"}}}
+
+	It("should convert the keys of fields without a tag name", func() {
+		result := ConvertStructToBSONMap(testUser, &MappingOpts{DefaultKeyCase: KeyCaseLowerCamel})
+		Expect(result).To(Equal(bson.M{
+			"_id":      "1",
+			"userName": "Test",
+			"metadata": bson.M{"lastActive": testTime, "Tags": []string{"a"}},
+		}))
+	})
+
+	It("should lower-case each segment of dot separated paths", func() {
+		result := FlattenToPairs(testUser, &MappingOpts{DefaultKeyCase: KeyCaseLower})
+		Expect(result).To(Equal([]PathValue{
+			{Path: "_id", Value: "1"},
+			{Path: "username", Value: "Test"},
+			{Path: "metadata.lastactive", Value: testTime},
+			{Path: "metadata.Tags.0", Value: "a"},
+		}))
+	})
+
+	It("should use the field's name as it is by default", func() {
+		result := ConvertStructToBSONMap(testUser, nil)
+		Expect(result).To(HaveKey("UserName"))
+		Expect(result).To(HaveKey("Metadata"))
+	})
+
+	DescribeTable("should apply the case to each segment of a key",
+		func(keyCase KeyCase, key string, expected string) {
+			Expect(keyCase.apply(key)).To(Equal(expected))
+		},
+		Entry("lower camel case", KeyCaseLowerCamel, "Metadata.LastActive", "metadata.lastActive"),
+		Entry("lower camel case with an acronym", KeyCaseLowerCamel, "HTTPServer.ID", "httpServer.id"),
+		Entry("lower camel case with an already lower segment", KeyCaseLowerCamel, "metadata.LastActive", "metadata.lastActive"),
+		Entry("lower case", KeyCaseLower, "Metadata.LastActive", "metadata.lastactive"),
+		Entry("unchanged", KeyCaseUnchanged, "Metadata.LastActive", "Metadata.LastActive"),
+	)
+})
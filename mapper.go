@@ -0,0 +1,80 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"sync"
+)
+
+// Mapper provides the same mapping functions as the package, however it holds a pool
+// of maps which are reused between calls in order to reduce allocations. It is intended
+// for high-throughput services which are repeatedly mapping structs.
+//
+// Once a map returned by the Mapper is no longer needed, it can be handed back with Release
+// so that it can be reused.
+//
+// Caveat: Release clears the map and hands it out to a later call, so it is unsafe to
+// keep using the map (or any reference to it, ie. if it has been stored in another map)
+// after it has been released. Only the top level map (and the document it's built from)
+// is pooled, nested data structures are always freshly allocated.
+type Mapper struct {
+	pool sync.Pool
+	docs sync.Pool
+}
+
+// NewMapper returns a Mapper with an empty pool of maps
+func NewMapper() *Mapper {
+	return &Mapper{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return bson.M{}
+			},
+		},
+		docs: sync.Pool{
+			New: func() interface{} {
+				return &bson.D{}
+			},
+		},
+	}
+}
+
+// ConvertStructToBSONMap behaves the same as the package level ConvertStructToBSONMap,
+// however the returned map is taken from the Mapper's pool
+func (m *Mapper) ConvertStructToBSONMap(s interface{}, opts *MappingOpts) bson.M {
+	out, _ := m.ConvertStructToBSONMapE(s, opts)
+	if len(out) == 0 {
+		m.Release(out)
+		return nil
+	}
+	return out
+}
+
+// ConvertStructToBSONMapE behaves the same as the package level ConvertStructToBSONMapE,
+// however the returned map is taken from the Mapper's pool
+func (m *Mapper) ConvertStructToBSONMapE(s interface{}, opts *MappingOpts) (bson.M, error) {
+	if err := checkStruct(s); err != nil {
+		return nil, err
+	}
+
+	n := NewBSONMapperStruct(s)
+	n.pool = &m.pool
+	n.docPool = &m.docs
+	out, err := n.ToBSONMapE(opts)
+	if err == nil && out == nil {
+		out = n.newMap()
+	}
+	return out, err
+}
+
+// Release clears a map which was returned by the Mapper and returns it to the pool
+//
+// The map must not be used after it has been released
+func (m *Mapper) Release(out bson.M) {
+	if out == nil {
+		return
+	}
+
+	for k := range out {
+		delete(out, k)
+	}
+	m.pool.Put(out)
+}
@@ -0,0 +1,92 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+type benchmarkStruct struct {
+	ID        string    `bson:"_id"`
+	FirstName string    `bson:"firstName"`
+	LastName  string    `bson:"lastName,omitempty"`
+	Age       int       `bson:"age"`
+	Active    bool      `bson:"active"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+var _ = Describe("Mapper", func() {
+	type valueStruct struct {
+		TestField1 string `bson:"testField1"`
+		TestField2 int    `bson:"testField2,omitempty"`
+	}
+
+	var mapper *Mapper
+
+	BeforeEach(func() {
+		mapper = NewMapper()
+	})
+
+	It("should map a struct in the same way as the package functions", func() {
+		testStruct := valueStruct{TestField1: "Test String", TestField2: 10}
+
+		result := mapper.ConvertStructToBSONMap(testStruct, nil)
+		Expect(result).To(Equal(ConvertStructToBSONMap(testStruct, nil)))
+	})
+
+	It("should return nil if a struct is not passed", func() {
+		result, err := mapper.ConvertStructToBSONMapE("Test String", nil)
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should return nil if all of the fields are omitted", func() {
+		result := mapper.ConvertStructToBSONMap(valueStruct{}, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(result).To(BeNil())
+	})
+
+	It("should return an empty map from the E variant if all of the fields are omitted", func() {
+		result, err := mapper.ConvertStructToBSONMapE(valueStruct{}, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(err).To(BeNil())
+		Expect(result).NotTo(BeNil())
+		Expect(result).To(BeEmpty())
+	})
+
+	It("should clear a map when it is released", func() {
+		result := mapper.ConvertStructToBSONMap(valueStruct{TestField1: "Test String"}, nil)
+		mapper.Release(result)
+		Expect(result).To(BeEmpty())
+	})
+
+	It("should not hold any stale keys after a map has been reused", func() {
+		mapper.Release(mapper.ConvertStructToBSONMap(valueStruct{TestField1: "Test String", TestField2: 10}, nil))
+
+		result := mapper.ConvertStructToBSONMap(valueStruct{TestField1: "Test String 2"}, nil)
+		Expect(result).To(Equal(bson.M{"testField1": "Test String 2"}))
+	})
+
+	It("should ignore a nil map being released", func() {
+		Expect(func() { mapper.Release(nil) }).NotTo(Panic())
+	})
+})
+
+func BenchmarkConvertStructToBSONMap(b *testing.B) {
+	s := benchmarkStruct{ID: "1", FirstName: "Jane", Age: 30, Active: true, CreatedAt: time.Now()}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertStructToBSONMap(s, nil)
+	}
+}
+
+func BenchmarkMapperConvertStructToBSONMap(b *testing.B) {
+	s := benchmarkStruct{ID: "1", FirstName: "Jane", Age: 30, Active: true, CreatedAt: time.Now()}
+	m := NewMapper()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Release(m.ConvertStructToBSONMap(s, nil))
+	}
+}
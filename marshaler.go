@@ -0,0 +1,46 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+)
+
+// The type of the bson.Marshaler interface, used to check whether a type implements it
+var marshalerType = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+
+// bsonMarshaler returns the value as a bson.Marshaler, if it implements it (including
+// through a pointer receiver, if the value is addressable) and isn't a nil pointer
+func bsonMarshaler(val reflect.Value) (bson.Marshaler, bool) {
+	if !val.IsValid() || ((val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && val.IsNil()) {
+		return nil, false
+	}
+
+	if m, ok := val.Interface().(bson.Marshaler); ok {
+		return m, true
+	}
+	if val.CanAddr() {
+		m, ok := val.Addr().Interface().(bson.Marshaler)
+		return m, ok
+	}
+	return nil, false
+}
+
+// implementsMarshaler checks whether the type (or a pointer to it) implements bson.Marshaler
+func implementsMarshaler(t reflect.Type) bool {
+	return t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType)
+}
+
+// bsonValue calls MarshalBSON on the value and decodes the returned document into a bson.D,
+// so that the value (including the order of it's keys) matches what the Mongo-Go Driver would write for it
+func bsonValue(m bson.Marshaler) (interface{}, error) {
+	b, err := m.MarshalBSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.D
+	if err := bson.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
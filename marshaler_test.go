@@ -0,0 +1,77 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// marshalerMoney marshals itself into a sub-document holding the amount in pounds
+type marshalerMoney struct {
+	Pence    int64
+	Currency string
+}
+
+func (m marshalerMoney) MarshalBSON() ([]byte, error) {
+	if m.Currency == "" {
+		return nil, errors.New("missing currency")
+	}
+	return bson.Marshal(bson.D{{Key: "currency", Value: m.Currency}, {Key: "amount", Value: float64(m.Pence) / 100}})
+}
+
+// marshalerCode marshals itself through a pointer receiver
+type marshalerCode string
+
+func (c *marshalerCode) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(bson.M{"code": string(*c)})
+}
+
+var _ = Describe("bson.Marshaler conversion", func() {
+	type testStruct struct {
+		Name    string           `bson:"name"`
+		Price   marshalerMoney   `bson:"price"`
+		Prices  []marshalerMoney `bson:"prices,omitempty"`
+		Deposit *marshalerMoney  `bson:"deposit"`
+	}
+
+	It("should store the document the value marshals to in it's order, rather than it's fields", func() {
+		result := ConvertStructToBSONMap(testStruct{
+			Name:    "Test",
+			Price:   marshalerMoney{Pence: 1250, Currency: "GBP"},
+			Prices:  []marshalerMoney{{Pence: 50, Currency: "EUR"}},
+			Deposit: &marshalerMoney{Pence: 100, Currency: "USD"},
+		}, nil)
+
+		Expect(result).To(Equal(bson.M{
+			"name":    "Test",
+			"price":   bson.D{{Key: "currency", Value: "GBP"}, {Key: "amount", Value: 12.5}},
+			"prices":  []interface{}{bson.D{{Key: "currency", Value: "EUR"}, {Key: "amount", Value: 0.5}}},
+			"deposit": bson.D{{Key: "currency", Value: "USD"}, {Key: "amount", Value: 1.0}},
+		}))
+	})
+
+	It("should leave a nil pointer as it is", func() {
+		result := ConvertStructToBSONMap(testStruct{Price: marshalerMoney{Currency: "GBP"}}, nil)
+		Expect(result).To(Equal(bson.M{
+			"name":    "",
+			"price":   bson.D{{Key: "currency", Value: "GBP"}, {Key: "amount", Value: 0.0}},
+			"deposit": (*marshalerMoney)(nil),
+		}))
+	})
+
+	It("should use a pointer receiver if the struct is addressable", func() {
+		testStruct := struct {
+			Code marshalerCode `bson:"code"`
+		}{Code: "abc"}
+
+		result := ConvertStructToBSONMap(&testStruct, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(result).To(Equal(bson.M{"code": bson.D{{Key: "code", Value: "abc"}}}))
+	})
+
+	It("should return an error if the value can't be marshalled", func() {
+		result, err := ConvertStructToBSONMapE(testStruct{Price: marshalerMoney{Pence: 100}}, nil)
+		Expect(result).To(BeNil())
+		Expect(err).To(MatchError(`unable to marshal "price" to BSON: missing currency`))
+	})
+})
@@ -0,0 +1,38 @@
+package mapper
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// ToBSONMapWithFieldNames behaves the same as ToBSONMap, however it also returns a map from each
+// key within the document to the name of the Go field it was mapped from. This supports building
+// reverse decoders, which need to know which field each key should be decoded into.
+//
+// Keys which are promoted from a nested struct or map ("flatten", "inline" or an embedded struct)
+// map to the name of the field they're held within, keys nested under a group (or RootKey) are
+// reported by their dot separated path. Keys which weren't mapped from a field (ie. ContextFields
+// or a generated "_id") aren't reported.
+//
+// Only the fields of the top level struct are reported
+func (s *StructToBSON) ToBSONMapWithFieldNames(opts *MappingOpts) (bson.M, map[string]string) {
+	out, fieldNames, _ := s.ToBSONMapWithFieldNamesE(opts)
+	return out, fieldNames
+}
+
+// ToBSONMapWithFieldNamesE behaves the same as ToBSONMapWithFieldNames, however it returns
+// an error if the struct can't be safely mapped
+func (s *StructToBSON) ToBSONMapWithFieldNamesE(opts *MappingOpts) (bson.M, map[string]string, error) {
+	n := *s
+	n.fieldNames = make(map[string]string)
+	out, err := n.ToBSONMapE(opts)
+	if err != nil || out == nil {
+		return nil, nil, err
+	}
+
+	if opts = withDefaults(opts); opts != nil && opts.RootKey != "" {
+		fieldNames := make(map[string]string, len(n.fieldNames))
+		for k, v := range n.fieldNames {
+			fieldNames[joinPath(opts.RootKey, k)] = v
+		}
+		return out, fieldNames, nil
+	}
+	return out, n.fieldNames, nil
+}
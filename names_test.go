@@ -0,0 +1,65 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var _ = Describe("ToBSONMapWithFieldNames", func() {
+	type address struct {
+		City string `bson:"city"`
+	}
+
+	type testStruct struct {
+		ID       string `bson:"_id"`
+		Name     string `bson:"name"`
+		Nickname string `bson:"nickname,omitempty"`
+		Untagged int
+		Address  address `bson:"address,flatten"`
+		Country  string  `bson:"country,group=location"`
+	}
+
+	It("should map each key to the name of the field it was mapped from", func() {
+		result, fieldNames := NewBSONMapperStruct(testStruct{ID: "1", Name: "Test", Untagged: 2, Address: address{City: "London"}, Country: "UK"}).
+			ToBSONMapWithFieldNames(nil)
+
+		Expect(result).To(Equal(bson.M{
+			"_id":      "1",
+			"name":     "Test",
+			"Untagged": 2,
+			"city":     "London",
+			"location": bson.M{"country": "UK"},
+		}))
+		Expect(fieldNames).To(Equal(map[string]string{
+			"_id":              "ID",
+			"name":             "Name",
+			"Untagged":         "Untagged",
+			"city":             "Address",
+			"location.country": "Country",
+		}))
+	})
+
+	It("should only report the keys which were mapped", func() {
+		_, fieldNames := NewBSONMapperStruct(testStruct{Name: "Test"}).ToBSONMapWithFieldNames(&MappingOpts{
+			GenerateFilterOrPatch: true,
+			ContextFields:         map[string]interface{}{"tenantId": "t1"},
+		})
+		Expect(fieldNames).To(Equal(map[string]string{"name": "Name"}))
+	})
+
+	It("should report the keys of renamed fields, nested under the RootKey", func() {
+		_, fieldNames := NewBSONMapperStruct(testStruct{Name: "Test"}).ToBSONMapWithFieldNames(&MappingOpts{
+			GenerateFilterOrPatch: true,
+			RenameKeys:            map[string]string{"name": "fullName"},
+			RootKey:               "data",
+		})
+		Expect(fieldNames).To(Equal(map[string]string{"data.fullName": "Name"}))
+	})
+
+	It("should return nil if the struct maps to nil", func() {
+		result, fieldNames := NewBSONMapperStruct(testStruct{}).ToBSONMapWithFieldNames(&MappingOpts{GenerateFilterOrPatch: true})
+		Expect(result).To(BeNil())
+		Expect(fieldNames).To(BeNil())
+	})
+})
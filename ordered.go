@@ -0,0 +1,110 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ToBSOND parses all struct fields and returns a bson.D { tagName: value }, which unlike
+// a bson.M preserves the order of the keys. The keys are ordered as follows:
+//
+// 	 // 1. "_id"
+// 	 // 2. Any fields with the "shardkey" tag option, in the order they're declared
+// 	 // 3. All other fields, in the order they're declared
+//
+// Nested structs are mapped into a bson.D as well, so their keys keep the order they're declared in.
+// The keys pulled out of nested structs by "flatten" or "inline" keep the order they're declared
+// in, while the keys pulled out of maps are sorted (nested maps are still mapped into a bson.M).
+//
+// The same options and tag options as ToBSONMap are factored into the parsing
+func (s *StructToBSON) ToBSOND(opts *MappingOpts) bson.D {
+	out, _ := s.ToBSONDE(opts)
+	return out
+}
+
+// ToBSONDE behaves the same as ToBSOND, however it returns an error
+// if the struct can't be safely mapped
+func (s *StructToBSON) ToBSONDE(opts *MappingOpts) (bson.D, error) {
+	opts = withOrderedNested(withDefaults(opts))
+	out, err := s.topLevelDoc(opts)
+	if err != nil || out == nil {
+		return nil, err
+	}
+	return wrapRoot(hoistKeys(out, append([]string{"_id"}, s.shardKeys(opts)...)), opts)
+}
+
+// ToBSONDocPartial parses all struct fields and returns a bson.D { tagName: value }, where only
+// the keys passed are guaranteed to be ordered. They're placed first in the order they're passed
+// (if they're present), while all other keys follow in a stable order (the order they're declared in).
+//
+// The same options and tag options as ToBSONMap are factored into the parsing
+func (s *StructToBSON) ToBSONDocPartial(opts *MappingOpts, orderedKeys []string) bson.D {
+	out, _ := s.ToBSONDocPartialE(opts, orderedKeys)
+	return out
+}
+
+// ToBSONDocPartialE behaves the same as ToBSONDocPartial, however it returns
+// an error if the struct can't be safely mapped
+func (s *StructToBSON) ToBSONDocPartialE(opts *MappingOpts, orderedKeys []string) (bson.D, error) {
+	opts = withOrderedNested(withDefaults(opts))
+	out, err := s.topLevelDoc(opts)
+	if err != nil || out == nil {
+		return nil, err
+	}
+	return wrapRoot(hoistKeys(out, orderedKeys), opts)
+}
+
+// withOrderedNested returns a copy of the options, which maps nested structs into a bson.D
+func withOrderedNested(opts *MappingOpts) *MappingOpts {
+	o := MappingOpts{}
+	if opts != nil {
+		o = *opts
+	}
+	o.orderedNested = true
+	return &o
+}
+
+// wrapRoot wraps the ordered document under the RootKey (if it's set), so that it keeps it's order
+func wrapRoot(doc bson.D, opts *MappingOpts) (bson.D, error) {
+	if opts == nil || opts.RootKey == "" {
+		return doc, nil
+	}
+	if err := validateKey(opts.RootKey, opts); err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: opts.RootKey, Value: doc}}, nil
+}
+
+// shardKeys returns the resolved keys of any fields with
+// the "shardkey" tag option, in the order they're declared
+func (s *StructToBSON) shardKeys(opts *MappingOpts) []string {
+	var keys []string
+	s.eachFieldKey(opts, func(key string, tagOpts tagOptions) {
+		if tagOpts.Has("shardkey") {
+			keys = append(keys, key)
+		}
+	})
+	return keys
+}
+
+// hoistKeys returns a copy of the document with the keys (if they're present) moved to the
+// front in the order they're passed, all other keys retain their original order
+func hoistKeys(doc bson.D, keys []string) bson.D {
+	out := make(bson.D, 0, len(doc))
+	hoisted := make(map[string]bool, len(keys))
+
+	for _, key := range keys {
+		for _, e := range doc {
+			if e.Key == key && !hoisted[key] {
+				out = append(out, e)
+				hoisted[key] = true
+			}
+		}
+	}
+
+	for _, e := range doc {
+		if !hoisted[e.Key] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
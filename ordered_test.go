@@ -0,0 +1,300 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var _ = Describe("ToBSOND", func() {
+	It("should preserve the order the fields are declared in", func() {
+		result := NewBSONMapperStruct(
+			struct {
+				TestField3 string `bson:"testField3"`
+				TestField1 int    `bson:"testField1"`
+				TestField2 bool   `bson:"testField2"`
+			}{
+				TestField3: "Test String",
+				TestField1: 10,
+				TestField2: true,
+			},
+		).ToBSOND(nil)
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "testField3", Value: "Test String"},
+			{Key: "testField1", Value: 10},
+			{Key: "testField2", Value: true},
+		}))
+	})
+
+	It("should place the _id first", func() {
+		result := NewBSONMapperStruct(
+			struct {
+				TestField1 string `bson:"testField1"`
+				ID         string `bson:"_id"`
+			}{
+				TestField1: "Test String",
+				ID:         "TEST ID",
+			},
+		).ToBSOND(nil)
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "_id", Value: "TEST ID"},
+			{Key: "testField1", Value: "Test String"},
+		}))
+	})
+
+	It("should place the shard key fields after the _id in the order they're declared", func() {
+		result := NewBSONMapperStruct(
+			struct {
+				TestField1 string `bson:"testField1"`
+				Region     string `bson:"region,shardkey"`
+				TestField2 int    `bson:"testField2"`
+				ID         string `bson:"_id"`
+				TenantID   string `bson:"tenantId,shardkey"`
+			}{
+				TestField1: "Test String",
+				Region:     "eu-west",
+				TestField2: 10,
+				ID:         "TEST ID",
+				TenantID:   "TEST TENANT",
+			},
+		).ToBSOND(nil)
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "_id", Value: "TEST ID"},
+			{Key: "region", Value: "eu-west"},
+			{Key: "tenantId", Value: "TEST TENANT"},
+			{Key: "testField1", Value: "Test String"},
+			{Key: "testField2", Value: 10},
+		}))
+	})
+
+	It("should ignore shard key fields which have been omitted", func() {
+		result := NewBSONMapperStruct(
+			struct {
+				TestField1 string `bson:"testField1"`
+				Region     string `bson:"region,shardkey,omitempty"`
+			}{
+				TestField1: "Test String",
+			},
+		).ToBSOND(nil)
+
+		Expect(result).To(Equal(bson.D{{Key: "testField1", Value: "Test String"}}))
+	})
+
+	It("should keep the declaration order of the keys within flattened structs", func() {
+		type address struct {
+			Street   string `bson:"street"`
+			City     string `bson:"city"`
+			Country  string `bson:"country"`
+			PostCode string `bson:"postCode,omitempty"`
+		}
+
+		type contact struct {
+			Phone string `bson:"phone"`
+			Email string `bson:"email"`
+		}
+
+		// Repeated to make sure the order is deterministic
+		for i := 0; i < 20; i++ {
+			result := NewBSONMapperStruct(
+				struct {
+					Name    string   `bson:"name"`
+					Address address  `bson:"address,flatten"`
+					Contact *contact `bson:"contact,flatten"`
+					Age     int      `bson:"age"`
+				}{
+					Name:    "Test",
+					Address: address{Street: "1 Test Street", City: "London", Country: "UK"},
+					Contact: &contact{Phone: "0123", Email: "test@test.com"},
+					Age:     30,
+				},
+			).ToBSOND(nil)
+
+			Expect(result).To(Equal(bson.D{
+				{Key: "name", Value: "Test"},
+				{Key: "street", Value: "1 Test Street"},
+				{Key: "city", Value: "London"},
+				{Key: "country", Value: "UK"},
+				{Key: "phone", Value: "0123"},
+				{Key: "email", Value: "test@test.com"},
+				{Key: "age", Value: 30},
+			}))
+		}
+	})
+
+	It("should map nested structs into a bson.D which keeps their declaration order", func() {
+		type geo struct {
+			Lng float64 `bson:"lng"`
+			Lat float64 `bson:"lat"`
+		}
+
+		type address struct {
+			Street string `bson:"street"`
+			City   string `bson:"city"`
+			Geo    *geo   `bson:"geo"`
+		}
+
+		result := NewBSONMapperStruct(
+			struct {
+				Name      string             `bson:"name"`
+				Address   address            `bson:"address"`
+				Previous  []address          `bson:"previous"`
+				Locations map[string]address `bson:"locations"`
+			}{
+				Name:      "Test",
+				Address:   address{Street: "1 Test Street", City: "London", Geo: &geo{Lng: 1, Lat: 2}},
+				Previous:  []address{{Street: "2 Test Street", City: "Paris"}},
+				Locations: map[string]address{"home": {Street: "3 Test Street", City: "Rome"}},
+			},
+		).ToBSOND(nil)
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "name", Value: "Test"},
+			{Key: "address", Value: bson.D{
+				{Key: "street", Value: "1 Test Street"},
+				{Key: "city", Value: "London"},
+				{Key: "geo", Value: bson.D{{Key: "lng", Value: 1.0}, {Key: "lat", Value: 2.0}}},
+			}},
+			{Key: "previous", Value: []interface{}{bson.D{
+				{Key: "street", Value: "2 Test Street"},
+				{Key: "city", Value: "Paris"},
+				{Key: "geo", Value: (*geo)(nil)},
+			}}},
+			{Key: "locations", Value: bson.M{"home": bson.D{
+				{Key: "street", Value: "3 Test Street"},
+				{Key: "city", Value: "Rome"},
+				{Key: "geo", Value: (*geo)(nil)},
+			}}},
+		}))
+	})
+
+	It("should still unwrap, key and list nested structs mapped into a bson.D", func() {
+		type item struct {
+			SKU string `bson:"sku"`
+			Qty int    `bson:"qty,omitempty"`
+		}
+
+		result := NewBSONMapperStruct(
+			struct {
+				Single item            `bson:"single,unwrap"`
+				ByID   []item          `bson:"byId,keyby=sku"`
+				List   map[string]item `bson:"list,tolist=key"`
+			}{
+				Single: item{SKU: "a"},
+				ByID:   []item{{SKU: "b", Qty: 1}},
+				List:   map[string]item{"c": {SKU: "c"}},
+			},
+		).ToBSOND(nil)
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "single", Value: "a"},
+			{Key: "byId", Value: bson.M{"b": bson.D{{Key: "sku", Value: "b"}, {Key: "qty", Value: 1}}}},
+			{Key: "list", Value: []interface{}{bson.D{{Key: "sku", Value: "c"}, {Key: "key", Value: "c"}}}},
+		}))
+	})
+
+	It("should produce the same content hash as ToBSONMap for nested structs", func() {
+		type address struct {
+			Street string `bson:"street"`
+			City   string `bson:"city"`
+		}
+		testStruct := NewBSONMapperStruct(struct {
+			Name    string  `bson:"name"`
+			Address address `bson:"address"`
+		}{Name: "Test", Address: address{Street: "1 Test Street", City: "London"}})
+		opts := &MappingOpts{ContentHashKey: "hash"}
+
+		ordered := testStruct.ToBSOND(opts)
+		Expect(ordered[len(ordered)-1].Value).To(Equal(testStruct.ToBSONMap(opts)["hash"]))
+	})
+
+	It("should wrap the ordered document under the RootKey", func() {
+		result := NewBSONMapperStruct(
+			struct {
+				Name string `bson:"name"`
+				ID   string `bson:"_id"`
+			}{Name: "Test", ID: "1"},
+		).ToBSOND(&MappingOpts{RootKey: "data"})
+
+		Expect(result).To(Equal(bson.D{{Key: "data", Value: bson.D{{Key: "_id", Value: "1"}, {Key: "name", Value: "Test"}}}}))
+	})
+
+	It("should return nil if every field is omitted", func() {
+		result := NewBSONMapperStruct(
+			struct {
+				TestField1 string `bson:"testField1"`
+			}{},
+		).ToBSOND(&MappingOpts{GenerateFilterOrPatch: true})
+
+		Expect(result).To(BeNil())
+	})
+
+	It("should return an error from the error API if a key is invalid", func() {
+		result, err := NewBSONMapperStruct(
+			struct {
+				TestField1 string `bson:"$testField1"`
+			}{},
+		).ToBSONDE(nil)
+
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
+
+var _ = Describe("ToBSONDocPartial", func() {
+	type testStruct struct {
+		Name     string `bson:"name"`
+		Region   string `bson:"region"`
+		ID       string `bson:"_id"`
+		Email    string `bson:"email,omitempty"`
+		Verified bool   `bson:"verified"`
+	}
+
+	testValue := testStruct{Name: "Test", Region: "EU", ID: "1", Verified: true}
+
+	It("should place the ordered keys first, in the order they're passed", func() {
+		result := NewBSONMapperStruct(testValue).ToBSONDocPartial(nil, []string{"_id", "region"})
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "_id", Value: "1"},
+			{Key: "region", Value: "EU"},
+			{Key: "name", Value: "Test"},
+			{Key: "verified", Value: true},
+		}))
+	})
+
+	It("should skip any ordered keys which aren't present", func() {
+		result := NewBSONMapperStruct(testValue).ToBSONDocPartial(nil, []string{"email", "verified"})
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "verified", Value: true},
+			{Key: "name", Value: "Test"},
+			{Key: "region", Value: "EU"},
+			{Key: "_id", Value: "1"},
+		}))
+	})
+
+	It("should keep the declared order if no keys are passed", func() {
+		result := NewBSONMapperStruct(testValue).ToBSONDocPartial(nil, nil)
+
+		Expect(result).To(Equal(bson.D{
+			{Key: "name", Value: "Test"},
+			{Key: "region", Value: "EU"},
+			{Key: "_id", Value: "1"},
+			{Key: "verified", Value: true},
+		}))
+	})
+
+	It("should return an error from the error API if a key is invalid", func() {
+		result, err := NewBSONMapperStruct(
+			struct {
+				TestField1 string `bson:"$testField1"`
+			}{},
+		).ToBSONDocPartialE(nil, []string{"_id"})
+
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
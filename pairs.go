@@ -0,0 +1,86 @@
+package mapper
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"sort"
+)
+
+// PathValue is a single leaf value within a mapped document, along with
+// the dot separated path to where it's stored within the document
+type PathValue struct {
+	Path  string
+	Value interface{}
+}
+
+// FlattenToPairs maps the struct (factoring in any options passed) and returns every leaf
+// value within the document, along with it's dot separated path. Elements of slices and arrays
+// are addressed by their index, ie. the path "addresses.0.city"
+//
+// The pairs are returned in the same order as ToBSOND, including the pairs within nested structs.
+// As nested maps are mapped into a bson.M (which has no defined order) their keys are sorted.
+// Empty documents and slices are treated as leaves, so that they're still represented.
+//
+// This is intended as a building block for diffing and change-tracking (ie. audit logs)
+func FlattenToPairs(s interface{}, opts *MappingOpts) []PathValue {
+	out, _ := FlattenToPairsE(s, opts)
+	return out
+}
+
+// FlattenToPairsE behaves the same as FlattenToPairs, however it returns
+// an error if the struct can't be mapped
+func FlattenToPairsE(s interface{}, opts *MappingOpts) ([]PathValue, error) {
+	if err := checkStruct(s); err != nil {
+		return nil, err
+	}
+
+	doc, err := NewBSONMapperStruct(s).ToBSONDE(opts)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return flattenPairs("", doc, nil), nil
+}
+
+// flattenPairs recursively walks the value, appending each leaf within it to the pairs
+func flattenPairs(path string, val interface{}, pairs []PathValue) []PathValue {
+	if d, ok := val.(bson.D); ok && len(d) > 0 {
+		for _, e := range d {
+			pairs = flattenPairs(joinPath(path, e.Key), e.Value, pairs)
+		}
+		return pairs
+	}
+
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return flattenPairs(path, v.Elem().Interface(), pairs)
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Len() == 0 {
+			break
+		}
+
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			pairs = flattenPairs(joinPath(path, k.String()), v.MapIndex(k).Interface(), pairs)
+		}
+		return pairs
+
+	case reflect.Slice, reflect.Array:
+		// Byte slices & arrays (ie. primitive.ObjectID) are stored as a single value
+		if v.Type().Elem().Kind() == reflect.Uint8 || v.Len() == 0 {
+			break
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			pairs = flattenPairs(joinPath(path, fmt.Sprint(i)), v.Index(i).Interface(), pairs)
+		}
+		return pairs
+	}
+
+	return append(pairs, PathValue{Path: path, Value: val})
+}
@@ -0,0 +1,89 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+var _ = Describe("FlattenToPairs", func() {
+	type address struct {
+		Street string `bson:"street"`
+		City   string `bson:"city"`
+	}
+
+	type user struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		Name      string             `bson:"name"`
+		Addresses []address          `bson:"addresses"`
+		Tags      []string           `bson:"tags"`
+		Meta      map[string]int     `bson:"meta"`
+		CreatedAt time.Time          `bson:"createdAt"`
+	}
+
+	testID := primitive.NewObjectID()
+	testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	It("should return the path of every leaf within a nested struct with a slice", func() {
+		result := FlattenToPairs(
+			user{
+				ID:   testID,
+				Name: "Test User",
+				Addresses: []address{
+					{Street: "1 Test Street", City: "London"},
+					{Street: "2 Test Street", City: "Paris"},
+				},
+				Tags:      []string{"first", "second"},
+				Meta:      map[string]int{"b": 2, "a": 1},
+				CreatedAt: testTime,
+			}, nil,
+		)
+
+		Expect(result).To(Equal([]PathValue{
+			{Path: "_id", Value: testID},
+			{Path: "name", Value: "Test User"},
+			{Path: "addresses.0.street", Value: "1 Test Street"},
+			{Path: "addresses.0.city", Value: "London"},
+			{Path: "addresses.1.street", Value: "2 Test Street"},
+			{Path: "addresses.1.city", Value: "Paris"},
+			{Path: "tags.0", Value: "first"},
+			{Path: "tags.1", Value: "second"},
+			{Path: "meta.a", Value: 1},
+			{Path: "meta.b", Value: 2},
+			{Path: "createdAt", Value: testTime},
+		}))
+	})
+
+	It("should treat empty slices and maps as leaves", func() {
+		result := FlattenToPairs(
+			struct {
+				Tags []string       `bson:"tags"`
+				Meta map[string]int `bson:"meta"`
+			}{
+				Tags: []string{},
+				Meta: map[string]int{},
+			}, nil,
+		)
+
+		Expect(result).To(Equal([]PathValue{
+			{Path: "tags", Value: []string{}},
+			{Path: "meta", Value: map[string]int{}},
+		}))
+	})
+
+	It("should factor in the mapping options", func() {
+		result := FlattenToPairs(
+			user{ID: testID, Name: "Test User"},
+			&MappingOpts{RemoveID: true, GenerateFilterOrPatch: true},
+		)
+
+		Expect(result).To(Equal([]PathValue{{Path: "name", Value: "Test User"}}))
+	})
+
+	It("should return an error from the error API if it isn't passed a struct", func() {
+		result, err := FlattenToPairsE("Test String", nil)
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
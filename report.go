@@ -0,0 +1,83 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"sort"
+)
+
+// SkipReason is the reason a field was skipped when the struct was mapped
+type SkipReason string
+
+const (
+	// SkipUnexported is reported for unexported fields, as their values can't be accessed
+	SkipUnexported SkipReason = "unexported"
+
+	// SkipIgnored is reported for fields with the "-" tag
+	SkipIgnored SkipReason = "ignored"
+
+	// SkipEmpty is reported for fields which were omitted as they're empty, due to the
+	// "omitempty" tag option or GenerateFilterOrPatch
+	SkipEmpty SkipReason = "empty"
+
+	// SkipCondition is reported for fields with the "when=flagName" tag option whose condition wasn't met
+	SkipCondition SkipReason = "condition not met"
+
+	// SkipExcluded is reported for fields which were excluded by the options (ie. RemoveID or SkipPointerFields)
+	SkipExcluded SkipReason = "excluded"
+)
+
+// SkippedField is a field which didn't appear in the mapped document, along with the reason it was skipped
+type SkippedField struct {
+	Field  string
+	Reason SkipReason
+}
+
+// ToBSONMapWithReport behaves the same as ToBSONMap, however it also reports which of the struct's
+// fields were skipped and why, in the order they're declared. This makes it transparent why a field
+// didn't appear in the document (ie. due to "omitempty" or GenerateFilterOrPatch).
+//
+// Only the fields of the top level struct are reported
+func (s *StructToBSON) ToBSONMapWithReport(opts *MappingOpts) (bson.M, []SkippedField) {
+	out, skipped, _ := s.ToBSONMapWithReportE(opts)
+	return out, skipped
+}
+
+// ToBSONMapWithReportE behaves the same as ToBSONMapWithReport, however it returns
+// an error if the struct can't be safely mapped
+func (s *StructToBSON) ToBSONMapWithReportE(opts *MappingOpts) (bson.M, []SkippedField, error) {
+	var skipped []SkippedField
+
+	// Unexported and ignored fields are never seen by the mapping logic, so they're reported up front
+	t := s.value.Type()
+	defaults := withDefaults(opts)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		switch {
+		case field.PkgPath != "":
+			skipped = append(skipped, SkippedField{Field: field.Name, Reason: SkipUnexported})
+		case s.fieldTag(field, defaults) == "-":
+			skipped = append(skipped, SkippedField{Field: field.Name, Reason: SkipIgnored})
+		}
+	}
+
+	n := *s
+	n.skipped = &skipped
+	out, err := n.ToBSONMapE(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.SliceStable(skipped, func(i, j int) bool {
+		a, _ := t.FieldByName(skipped[i].Field)
+		b, _ := t.FieldByName(skipped[j].Field)
+		return a.Index[0] < b.Index[0]
+	})
+	return out, skipped, nil
+}
+
+// skip records that the field was skipped, if the skipped fields are being reported
+func (s *StructToBSON) skip(field string, reason SkipReason) {
+	if s.skipped != nil {
+		*s.skipped = append(*s.skipped, SkippedField{Field: field, Reason: reason})
+	}
+}
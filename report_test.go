@@ -0,0 +1,71 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var _ = Describe("ToBSONMapWithReport", func() {
+	type address struct {
+		City string `bson:"city"`
+	}
+
+	type testStruct struct {
+		ID       string `bson:"_id"`
+		Name     string `bson:"name"`
+		Nickname string `bson:"nickname,omitempty"`
+		secret   string
+		Password string   `bson:"-"`
+		Beta     bool     `bson:"beta,when=beta"`
+		Address  *address `bson:"address"`
+		Tags     []string `bson:"tags,omitempty"`
+	}
+
+	It("should report why each of the fields was skipped", func() {
+		result, skipped := NewBSONMapperStruct(testStruct{ID: "1", Name: "Test", secret: "secret", Password: "password"}).
+			ToBSONMapWithReport(&MappingOpts{RemoveID: true})
+
+		Expect(result).To(Equal(bson.M{"name": "Test", "address": (*address)(nil)}))
+		Expect(skipped).To(Equal([]SkippedField{
+			{Field: "ID", Reason: SkipExcluded},
+			{Field: "Nickname", Reason: SkipEmpty},
+			{Field: "secret", Reason: SkipUnexported},
+			{Field: "Password", Reason: SkipIgnored},
+			{Field: "Beta", Reason: SkipCondition},
+			{Field: "Tags", Reason: SkipEmpty},
+		}))
+	})
+
+	It("should report the empty fields omitted by GenerateFilterOrPatch", func() {
+		result, skipped := NewBSONMapperStruct(testStruct{Name: "Test", Tags: []string{"a"}}).
+			ToBSONMapWithReport(&MappingOpts{GenerateFilterOrPatch: true, SkipPointerFields: true, Conditions: map[string]bool{"beta": true}})
+
+		Expect(result).To(Equal(bson.M{"name": "Test", "tags": []string{"a"}}))
+		Expect(skipped).To(Equal([]SkippedField{
+			{Field: "ID", Reason: SkipEmpty},
+			{Field: "Nickname", Reason: SkipEmpty},
+			{Field: "secret", Reason: SkipUnexported},
+			{Field: "Password", Reason: SkipIgnored},
+			{Field: "Beta", Reason: SkipEmpty},
+			{Field: "Address", Reason: SkipExcluded},
+		}))
+	})
+
+	It("should report nothing if every field is mapped", func() {
+		result, skipped := NewBSONMapperStruct(address{City: "London"}).ToBSONMapWithReport(nil)
+
+		Expect(result).To(Equal(bson.M{"city": "London"}))
+		Expect(skipped).To(BeEmpty())
+	})
+
+	It("should return an error from the error API if the struct can't be mapped", func() {
+		result, skipped, err := NewBSONMapperStruct(struct {
+			Name string `bson:"$name"`
+		}{}).ToBSONMapWithReportE(nil)
+
+		Expect(result).To(BeNil())
+		Expect(skipped).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,213 @@
+package mapper
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"reflect"
+	"time"
+)
+
+// BSONMapToStruct is the inverse of ConvertStructToBSONMap, it populates the struct the target points to
+// from the map (ie. a document returned by a query), honouring the same tags the mapper uses.
+//
+// Each field is looked up in the map by it's tag name (or it's field name if it doesn't have one) and
+// fields tagged with "-" are skipped. The fields of anonymous embedded structs, and of nested structs with
+// the "flatten" or "inline" tag option, are looked up in the same map as the parent's. Nested documents are
+// recursed into, nil pointers are allocated as they're needed and a primitive.DateTime is converted to a time.Time.
+// Tag options which transform the value being stored (ie. "string" or "hex") aren't reversed.
+//
+// Keys which aren't held in the map leave their fields as they are. Returns an error wrapping ErrNotAStruct if
+// the target isn't a pointer to a struct, or ErrCannotAssign if a value can't be assigned to it's field
+func BSONMapToStruct(m bson.M, target interface{}) error {
+	if v := reflect.ValueOf(target); v.Kind() != reflect.Ptr {
+		return fmt.Errorf("expected pointer to struct, got %s: %w", v.Kind(), ErrNotAStruct)
+	}
+
+	s, err := NewBSONMapperStructE(target)
+	if err != nil {
+		return err
+	}
+	return s.fromBSONMap(m)
+}
+
+// fromBSONMap assigns the values held in the map to the struct's fields
+func (s *StructToBSON) fromBSONMap(m bson.M) error {
+	for _, field := range s.structFields(nil) {
+		tagName, tagOpts := parseTag(s.fieldTag(field, nil))
+		fieldVal := s.value.FieldByIndex(field.Index)
+
+		// Promoted fields are held within the parent's map, rather than under their own key
+		promoted := (field.Anonymous && tagName == "") || tagOpts.Has("flatten") || tagOpts.Has("inline")
+		if t := indirectType(field.Type); promoted && t.Kind() == reflect.Struct && !isDriverType(reflect.Zero(t).Interface()) {
+			if err := assignPromoted(fieldVal, m); err != nil {
+				return fmt.Errorf("unable to assign %q: %w", field.Name, err)
+			}
+			continue
+		}
+
+		key := field.Name
+		if tagName != "" {
+			key = tagName
+		}
+
+		val, ok := m[key]
+		if !ok {
+			continue
+		}
+		if err := assignValue(fieldVal, val); err != nil {
+			return fmt.Errorf("unable to assign %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// assignPromoted populates the (pointer to a) struct from the parent's map, allocating it if it's nil
+func assignPromoted(dst reflect.Value, m bson.M) error {
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	n := NewBSONMapperStruct(dst.Addr().Interface())
+	n.TagName = DefaultTagName
+	return n.fromBSONMap(m)
+}
+
+// assignValue assigns the value to dst, recursing into nested documents and arrays
+func assignValue(dst reflect.Value, val interface{}) error {
+	if val == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	src := reflect.ValueOf(val)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		if err := assignValue(elem.Elem(), val); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case reflect.Struct:
+		if dt, ok := val.(primitive.DateTime); ok && dst.Type() == reflect.TypeOf(time.Time{}) {
+			dst.Set(reflect.ValueOf(dt.Time()))
+			return nil
+		}
+		if doc, ok := toBSONMap(val); ok && !isDriverType(dst.Interface()) {
+			n := NewBSONMapperStruct(dst.Addr().Interface())
+			return n.fromBSONMap(doc)
+		}
+	case reflect.Slice:
+		if src.Kind() == reflect.Slice || src.Kind() == reflect.Array {
+			out := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+			for i := 0; i < src.Len(); i++ {
+				if err := assignValue(out.Index(i), src.Index(i).Interface()); err != nil {
+					return fmt.Errorf("index %d: %w", i, err)
+				}
+			}
+			dst.Set(out)
+			return nil
+		}
+	case reflect.Map:
+		if doc, ok := toBSONMap(val); ok && dst.Type().Key().Kind() == reflect.String {
+			out := reflect.MakeMapWithSize(dst.Type(), len(doc))
+			for k, v := range doc {
+				elem := reflect.New(dst.Type().Elem()).Elem()
+				if err := assignValue(elem, v); err != nil {
+					return fmt.Errorf("key %q: %w", k, err)
+				}
+				out.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+			}
+			dst.Set(out)
+			return nil
+		}
+	}
+
+	// Numbers are converted between their sizes (ie. an int32 which fit into a smaller size
+	// for storage), any other conversions (ie. an int to a string) would change the value
+	if isNumberKind(src.Kind()) && isNumberKind(dst.Kind()) {
+		converted, ok := convertNumber(src, dst.Type())
+		if !ok {
+			return fmt.Errorf("can't assign %T %v to %s without losing data: %w", val, val, dst.Type(), ErrCannotAssign)
+		}
+		dst.Set(converted)
+		return nil
+	}
+	if src.Kind() == dst.Kind() && src.Type().ConvertibleTo(dst.Type()) {
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("can't assign %T to %s: %w", val, dst.Type(), ErrCannotAssign)
+}
+
+// toBSONMap returns the document held by the value as a bson.M
+func toBSONMap(val interface{}) (bson.M, bool) {
+	switch v := val.(type) {
+	case bson.M:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	case bson.D:
+		m := make(bson.M, len(v))
+		for _, e := range v {
+			m[e.Key] = e.Value
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// indirectType returns the type the pointer type points to, or the type itself if it isn't a pointer
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// convertNumber converts the number to the type, as long as it can be converted back to the same value.
+// Returns false if the number would overflow, be truncated or change sign (ie. 300 into an int8, 3.9 into
+// an int or -1 into a uint)
+func convertNumber(src reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	converted := src.Convert(t)
+	if converted.Convert(src.Type()).Interface() != src.Interface() {
+		// NaN is never equal to itself, however it's still NaN once converted
+		isNaN := func(v reflect.Value) bool {
+			return (v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64) && math.IsNaN(v.Float())
+		}
+		return converted, isNaN(src) && isNaN(converted)
+	}
+	return converted, isNegative(src) == isNegative(converted)
+}
+
+// isNegative checks whether the number is less than 0
+func isNegative(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() < 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() < 0
+	}
+	return false
+}
+
+// isNumberKind checks whether the kind is an integer or float
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"time"
+)
+
+var _ = Describe("BSONMapToStruct", func() {
+	type Base struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	type address struct {
+		City     string `bson:"city"`
+		Postcode string `bson:"postcode"`
+	}
+	type audit struct {
+		CreatedBy string `bson:"createdBy"`
+	}
+	type user struct {
+		Base
+		FirstName string         `bson:"firstName"`
+		Age       int            `bson:"age"`
+		Score     float64        `bson:"score"`
+		Nickname  *string        `bson:"nickname"`
+		Address   address        `bson:"address"`
+		Previous  *address       `bson:"previous"`
+		History   []address      `bson:"history"`
+		Tags      []string       `bson:"tags"`
+		Meta      map[string]int `bson:"meta"`
+		Audit     audit          `bson:"audit,flatten"`
+		Created   time.Time      `bson:"created"`
+		Secret    string         `bson:"-"`
+		Untagged  string
+	}
+
+	testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	It("should populate the struct from the map using the bson tags", func() {
+		id := primitive.NewObjectID()
+		var result user
+		err := BSONMapToStruct(bson.M{
+			"_id":       id,
+			"firstName": "Test User",
+			"age":       int32(30),
+			"score":     int64(5),
+			"nickname":  "Test",
+			"address":   bson.M{"city": "London", "postcode": "N1"},
+			"previous":  bson.D{{Key: "city", Value: "Leeds"}},
+			"history":   bson.A{bson.M{"city": "York"}},
+			"tags":      bson.A{"a", "b"},
+			"meta":      bson.M{"visits": int32(2)},
+			"createdBy": "admin",
+			"created":   primitive.NewDateTimeFromTime(testTime),
+			"Secret":    "ignored",
+			"Untagged":  "value",
+		}, &result)
+		Expect(err).ToNot(HaveOccurred())
+
+		nickname := "Test"
+		Expect(result).To(Equal(user{
+			Base:      Base{ID: id},
+			FirstName: "Test User",
+			Age:       30,
+			Score:     5,
+			Nickname:  &nickname,
+			Address:   address{City: "London", Postcode: "N1"},
+			Previous:  &address{City: "Leeds"},
+			History:   []address{{City: "York"}},
+			Tags:      []string{"a", "b"},
+			Meta:      map[string]int{"visits": 2},
+			Audit:     audit{CreatedBy: "admin"},
+			Created:   primitive.NewDateTimeFromTime(testTime).Time(),
+			Untagged:  "value",
+		}))
+	})
+
+	It("should round trip the output of ConvertStructToBSONMap", func() {
+		nickname := "Test"
+		original := user{
+			FirstName: "Test User",
+			Nickname:  &nickname,
+			Address:   address{City: "London"},
+			History:   []address{{City: "York"}},
+			Audit:     audit{CreatedBy: "admin"},
+			Created:   testTime,
+		}
+
+		var result user
+		Expect(BSONMapToStruct(ConvertStructToBSONMap(original, nil), &result)).To(Succeed())
+		Expect(result).To(Equal(original))
+	})
+
+	It("should leave fields which aren't held in the map as they are, and clear those holding nil", func() {
+		nickname := "Test"
+		result := user{FirstName: "Test User", Nickname: &nickname}
+		Expect(BSONMapToStruct(bson.M{"nickname": nil}, &result)).To(Succeed())
+		Expect(result).To(Equal(user{FirstName: "Test User"}))
+	})
+
+	It("should return an error if a value can't be assigned to it's field", func() {
+		var result user
+		err := BSONMapToStruct(bson.M{"age": "thirty"}, &result)
+		Expect(errors.Is(err, ErrCannotAssign)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring(`"age"`)))
+	})
+
+	Context("when converting numbers between types", func() {
+		type sizes struct {
+			Small    int8    `bson:"small"`
+			Count    int     `bson:"count"`
+			Unsigned uint    `bson:"unsigned"`
+			Ratio    float32 `bson:"ratio"`
+		}
+
+		It("should convert numbers which fit into the field's type", func() {
+			var result sizes
+			Expect(BSONMapToStruct(bson.M{"small": int32(100), "count": 3.0, "unsigned": int64(7), "ratio": 0.5}, &result)).To(Succeed())
+			Expect(result).To(Equal(sizes{Small: 100, Count: 3, Unsigned: 7, Ratio: 0.5}))
+		})
+
+		DescribeTable("should return an error if the number can't be held by the field without losing data",
+			func(m bson.M, key string) {
+				var result sizes
+				err := BSONMapToStruct(m, &result)
+				Expect(errors.Is(err, ErrCannotAssign)).To(BeTrue())
+				Expect(err).To(MatchError(ContainSubstring(`"` + key + `"`)))
+			},
+			Entry("overflowing an int8", bson.M{"small": int32(300)}, "small"),
+			Entry("truncating a float", bson.M{"count": 3.9}, "count"),
+			Entry("a negative number into a uint", bson.M{"unsigned": int64(-1)}, "unsigned"),
+			Entry("overflowing a float32", bson.M{"ratio": math.MaxFloat64}, "ratio"),
+		)
+	})
+
+	It("should return an error if the target isn't a pointer to a struct", func() {
+		Expect(errors.Is(BSONMapToStruct(bson.M{}, user{}), ErrNotAStruct)).To(BeTrue())
+		Expect(errors.Is(BSONMapToStruct(bson.M{}, (*user)(nil)), ErrNilStruct)).To(BeTrue())
+	})
+})
@@ -0,0 +1,33 @@
+package mapper
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"runtime/debug"
+	"strings"
+)
+
+// The number of lines of the stack trace which are included in the error returned by SafeConvert
+const panicStackLines = 20
+
+// SafeConvert behaves the same as ConvertStructToBSONMapE, however it also recovers from any panic
+// while the struct is being mapped (ie. from a Stringer, encoder or KeySanitizer which panics) and
+// returns it as an error wrapping ErrPanic, which includes a snippet of the stack trace
+func SafeConvert(s interface{}, opts *MappingOpts) (result bson.M, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = fmt.Errorf("recovered from %v: %w\n%s", r, ErrPanic, stackSnippet(debug.Stack()))
+		}
+	}()
+	return ConvertStructToBSONMapE(s, opts)
+}
+
+// stackSnippet returns the first panicStackLines lines of the stack trace
+func stackSnippet(stack []byte) string {
+	lines := strings.SplitN(string(stack), "\n", panicStackLines+1)
+	if len(lines) > panicStackLines {
+		lines = lines[:panicStackLines]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,56 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+)
+
+// A Stringer which panics, as it dereferences the nil map it holds
+type testPanickingStringer struct {
+	values map[string]*string
+}
+
+func (p testPanickingStringer) String() string {
+	return *p.values["missing"]
+}
+
+var _ = Describe("SafeConvert", func() {
+	It("should behave the same as ConvertStructToBSONMapE", func() {
+		result, err := SafeConvert(struct {
+			Name string `bson:"name"`
+		}{Name: "Test"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(bson.M{"name": "Test"}))
+
+		_, err = SafeConvert("not a struct", nil)
+		Expect(errors.Is(err, ErrNotAStruct)).To(BeTrue())
+	})
+
+	It("should return a panic while mapping as an error", func() {
+		result, err := SafeConvert(struct {
+			Value testPanickingStringer `bson:"value,string"`
+		}{}, nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrPanic)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("nil pointer dereference")))
+		Expect(err).To(MatchError(ContainSubstring("goroutine")))
+	})
+
+	It("should return a panic from the KeySanitizer as an error", func() {
+		_, err := SafeConvert(struct {
+			Name string `bson:"name"`
+		}{Name: "Test"}, &MappingOpts{KeySanitizer: func(key string) (string, error) {
+			panic("sanitizer failed")
+		}})
+		Expect(errors.Is(err, ErrPanic)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("sanitizer failed")))
+	})
+
+	It("should only include a snippet of the stack trace", func() {
+		stack := strings.Repeat("frame\n", panicStackLines*2)
+		Expect(strings.Count(stackSnippet([]byte(stack)), "\n")).To(Equal(panicStackLines - 1))
+	})
+})
@@ -8,6 +8,11 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Package built based off https://github.com/fatih/structs/
@@ -18,6 +23,10 @@ var (
 	// in the mapping struct (StructToBSON) by chaining the
 	// .SetTagName() call on the wrapped struct.
 	DefaultTagName = "bson"
+
+	// By default, Descriptions reads the per-field descriptions from
+	// the `description` tag, this can be changed with DescriptionTagName
+	DefaultDescriptionTagName = "description"
 )
 
 // StructToBson is the wrapper for a struct that enables this package to work
@@ -25,6 +34,29 @@ type StructToBSON struct {
 	raw     interface{}
 	value   reflect.Value
 	TagName string
+
+	// Only set when the struct is being mapped by a Mapper
+	pool    *sync.Pool
+	docPool *sync.Pool
+
+	// The document taken from the docPool that the struct's fields are mapped into
+	doc *bson.D
+
+	// Whether the struct is nested within the struct being mapped
+	nested bool
+
+	// The tag name of the top level struct, which nested structs fall back to
+	rootTagName string
+
+	// Whether the struct is nested below a field with the "resettags" tag option,
+	// in which case the FallbackTagName isn't parsed
+	resetTags bool
+
+	// Only set when the skipped fields are being reported (see ToBSONMapWithReport)
+	skipped *[]SkippedField
+
+	// Only set when the field names are being reported (see ToBSONMapWithFieldNames)
+	fieldNames map[string]string
 }
 
 // MappingOpts allows the setting of options which drive the behaviour behind how the struct is parsed
@@ -50,14 +82,271 @@ type MappingOpts struct {
 	// If true, it will check all struct fields for zero type values and
 	// omit any that are found regardless of any tag options, effectively it enforces
 	// the behaviour of the "omitempty" tag, regardless of whether the struct field
-	// has it or not
+	// has it or not. Fields with the "keepempty" tag option are kept even when empty
 	//
 	// This logic occurs after UseIDifAvailable & RemoveID
 	//
 	// 	// Default: False
 	GenerateFilterOrPatch bool
+
+	// If greater than 0, any resolved key longer than this will cause the error
+	// returning functions (ConvertStructToBSONMapE & ToBSONMapE) to return an error.
+	//
+	// Regardless of this option, keys that are prefixed with "$", or that contain
+	// a "." or a null byte are always rejected as MongoDB can't safely store them.
+	// The keys of maps within the struct are only rejected if they contain a null byte
+	// or exceed this limit (see KeySanitizer)
+	//
+	// 	// Default: 0 (no limit)
+	MaxKeyLength int
+
+	// A sentinel time which should be treated as "unset", ie. time.Unix(0, 0)
+	//
+	// When set, any time.Time or *time.Time field equal to it is omitted in the same
+	// way as a zero time, whenever the "omitempty" tag or GenerateFilterOrPatch applies.
+	//
+	// 	// Default: time.Time{} (only the zero time is omitted)
+	NullTime time.Time
+
+	// A hook which is called with every resolved key (including the keys of any maps
+	// within the struct) before it is validated, allowing keys to either be sanitized
+	// or rejected. ie. replacing any "." with "_". Once they've been sanitized, the keys
+	// of maps are only checked for null bytes and the MaxKeyLength, other than in update
+	// documents where they're validated the same as any other key
+	//
+	// If it returns an error, the error returning functions will return it
+	//
+	// 	// Default: nil
+	KeySanitizer func(key string) (string, error)
+
+	// If true, once the struct has been mapped every value in the output is checked to
+	// make sure it is a type which the Mongo-Go Driver is able to encode (ie. scalars,
+	// time.Time, primitive.ObjectID, primitive.Decimal128, primitive.Binary etc.) and
+	// the error returning functions will return an error if it is not. This allows
+	// encoding failures to be caught when mapping, rather than at insert time.
+	//
+	// 	// Default: False
+	ValidateEncodable bool
+
+	// Renames keys in the output, mapping the resolved key of a field to the key
+	// it should be stored under. ie. { "lastActive": "lastSeen" }
+	//
+	// By default only the keys of the top level struct are renamed
+	//
+	// 	// Default: nil
+	RenameKeys map[string]string
+
+	// If true, RenameKeys is also applied to the keys of any nested structs
+	//
+	// 	// Default: False
+	RenameKeysRecursive bool
+
+	// If set, a hash of the mapped document's content is added to the top level document
+	// under this key, ie. for use as an etag. The hash is computed over the canonical
+	// serialisation of the document (see CanonicalBytes) so it is stable regardless
+	// of the order the struct fields are declared in.
+	//
+	// 	// Default: "" (no hash is added)
+	ContentHashKey string
+
+	// The runtime conditions which fields with the "when=flagName" tag option depend on,
+	// those fields are only included if Conditions[flagName] is true
+	//
+	// 	// Default: nil (no conditional fields are included)
+	Conditions map[string]bool
+
+	// If set, ConvertStructToUpdateBSON will set the current time under
+	// this key within the "$set" of every update it generates
+	//
+	// 	// Default: "" (no time is set)
+	AutoUpdatedAtKey string
+
+	// The time source used for AutoUpdatedAtKey, allowing the time to be fixed in tests
+	//
+	// 	// Default: nil (time.Now().UTC() is used)
+	NowFunc func() time.Time
+
+	// If true, rather than omitting empty strings whenever the "omitempty" tag or
+	// GenerateFilterOrPatch applies, they are stored as primitive.Null{}. This allows
+	// a string field to be explicitly cleared in an update.
+	//
+	// 	// Default: False
+	EmptyStringAsNull bool
+
+	// If greater than 0, any string value longer than this (in bytes), including the strings within
+	// slices, arrays and maps, will cause the error returning functions to return an error which
+	// reports the offending key.
+	// This guards against oversized free-text fields bloating the document.
+	//
+	// 	// Default: 0 (no limit)
+	MaxStringLen int
+
+	// If true, rather than returning an error any string value longer than
+	// the MaxStringLen is truncated to fit within it
+	//
+	// 	// Default: False
+	TruncateLongStrings bool
+
+	// If true, a new ObjectID is set under "_id" in the top level document when the
+	// struct's "_id" field is either absent or holds a zero value, ready for an insert.
+	// It has no effect when RemoveID is true, or when generating an update document.
+	//
+	// 	// Default: False
+	GenerateIDIfMissing bool
+
+	// The ID source used for GenerateIDIfMissing, allowing the ID to be fixed in tests
+	//
+	// 	// Default: nil (primitive.NewObjectID() is used)
+	IDGenerator func() primitive.ObjectID
+
+	// If true, any field which implements json.Marshaler is stored as it's JSON representation
+	// (as a bson.M for JSON objects), in the same way as fields with the "json" tag option.
+	// Types which the Mongo-Go Driver already supports (ie. time.Time) are left as they are.
+	//
+	// 	// Default: False
+	UseJSONMarshaler bool
+
+	// The tag name to parse for nested structs of a given type, allowing struct types which
+	// use different tag conventions to be mapped together. ie. { reflect.TypeOf(Address{}): "db" }
+	//
+	// Nested structs whose type isn't present fall back to the tag name of the top level struct
+	//
+	// 	// Default: nil
+	TagNameByType map[reflect.Type]string
+
+	// By default the fields of anonymous embedded structs (without an explicit key in their tag)
+	// are inlined into the parent document, in the same way as the "inline" tag option.
+	// If true, they're instead nested in a subdocument under the embedded type's name.
+	//
+	// 	// Default: False
+	EmbeddedAsSubdocument bool
+
+	// If true, any field whose type is a pointer is omitted regardless of the value it
+	// holds (including within nested structs), so only value fields are mapped
+	//
+	// 	// Default: False
+	SkipPointerFields bool
+
+	// Request scoped metadata (ie. { "tenantId": tenantID }) which is merged into the top level
+	// document, avoiding the need to thread it through every struct. If a key is also held by one
+	// of the struct's fields, the value in ContextFields takes precedence.
+	//
+	// 	// Default: nil
+	ContextFields map[string]interface{}
+
+	// If true, the keys of any maps with the "inline" tag option are lowercased as they're
+	// pulled up into the parent document, while the keys of typed fields keep their casing
+	//
+	// 	// Default: False
+	LowercaseInlinedMapKeys bool
+
+	// Nested struct types which shouldn't be recursively mapped, instead their values are passed
+	// through as they are (as if every field of the type had the "omitnested" tag option)
+	//
+	// 	// Default: nil
+	OpaqueTypes []reflect.Type
+
+	// If true, empty strings are kept when GenerateFilterOrPatch applies, allowing a filter to match
+	// on "" exactly. Fields with the "omitempty" tag option still omit their empty strings
+	//
+	// 	// Default: False
+	KeepEmptyStrings bool
+
+	// If set, the mapped document is wrapped under this key at the top level, ie. { "data": { ... } }
+	//
+	// 	// Default: "" (the document isn't wrapped)
+	RootKey string
+
+	// The case the keys of fields without a tag name are converted to, rather than using the field's
+	// name as it is. Dot separated keys have the case applied to each segment of their path
+	//
+	// 	// Default: KeyCaseUnchanged
+	DefaultKeyCase KeyCase
+
+	// If true, a field with the "numify" tag option which doesn't hold a valid number causes the
+	// error returning functions to return an error, rather than the string being passed through
+	//
+	// 	// Default: False
+	StrictNumify bool
+
+	// The kinds which fields aren't allowed to hold (ie. reflect.Map), including through a pointer or interface
+	// and as the elements of a slice, array or map (ie. []chan int or map[string]func()).
+	// If a field of a forbidden kind is encountered, the error returning functions return an error which
+	// reports the offending key. This enforces schema discipline in generic code.
+	//
+	// 	// Default: nil
+	ForbiddenKinds []reflect.Kind
+
+	// If true, nested structs and maps are flattened into dot separated key paths rather than
+	// being held as sub-documents, ie. { "metadata.lastActive": t } rather than { "metadata": { "lastActive": t } }.
+	// This allows a partial update to set nested fields without replacing the whole sub-document.
+	// The structs within slices and arrays are addressed by their index, ie. "items.0.name".
+	//
+	// Fields with the "omitnested" tag option, types with custom marshalling, the OpaqueTypes and
+	// slices which don't hold structs aren't flattened.
+	//
+	// 	// Default: False
+	UseDotNotation bool
+
+	// The name of the struct tag which Descriptions reads the per-field descriptions from
+	//
+	// 	// Default: "description"
+	DescriptionTagName string
+
+	// If true, any duplicate bool, number or string elements are removed from slices and arrays (ie. for
+	// building "$addToSet" style documents client-side), keeping the order the elements first appear in.
+	// Any other elements (ie. structs) are left as they are
+	//
+	// 	// Default: False
+	DedupeSlices bool
+
+	// The layout time.Time fields with the "string" or "stringkey" tag options are formatted with
+	// (ie. time.RFC3339), rather than using their String method. Including pointers to a time.Time
+	//
+	// 	// Default: ""
+	TimeLayout string
+
+	// The tag name which is parsed for fields that don't have the primary tag (or have an empty one),
+	// ie. "json", so fields which are only annotated for other encodings can reuse those annotations.
+	// Any tag options held in the fallback tag (ie. "omitempty") are applied as well. Nested structs
+	// inherit it, unless they're held by a field with the "resettags" tag option
+	//
+	// 	// Default: ""
+	FallbackTagName string
+
+	// If true, any time.Time (or *time.Time) values are stored as a primitive.DateTime, with the millisecond
+	// precision they'd be stored with, including those nested within slices and maps. Zero times are still
+	// omitted before they're converted whenever "omitempty" or GenerateFilterOrPatch applies
+	//
+	// 	// Default: False
+	ConvertTimeToDateTime bool
+
+	// If true, an "_id" field holding a 24 character hex string (or a pointer to one) is stored as the
+	// primitive.ObjectID it represents, so it matches the ObjectIDs stored by MongoDB. Strings which
+	// aren't a valid ObjectID are stored as they are. This also applies when UseIDifAvailable short-circuits
+	//
+	// 	// Default: False
+	CoerceObjectID bool
+
+	// Only set when generating an update pipeline, where keys which contain a "."
+	// or are prefixed with "$" can be set using "$setField"
+	allowSetFieldKeys bool
+
+	// Only set when generating an update document, where the keys of maps (ie. those pulled up by "inline")
+	// would be treated as a path or an operator, so they're validated the same as any other key
+	validateMapKeys bool
+
+	// Only set when generating ordered output, where nested structs are mapped into a bson.D
+	orderedNested bool
 }
 
+// The precedence of keys which are promoted from nested data structures, explicit
+// fields always take precedence over any keys which have been promoted
+const (
+	flattenPrecedence = iota
+	inlinePrecedence
+)
+
 // NewBSONMapperStruct returns the input struct wrapped by the mapper struct
 // along with the tag name which should be parsed in the mapping
 //
@@ -70,6 +359,22 @@ func NewBSONMapperStruct(s interface{}) *StructToBSON {
 	}
 }
 
+// NewBSONMapperStructE behaves the same as NewBSONMapperStruct, however rather than panicking
+// it returns an error wrapping ErrNotAStruct if the argument is not a struct or pointer to a struct
+// (the error reports the kind that was passed), or ErrNilStruct (which also wraps ErrNotAStruct)
+// if it's a nil pointer to a struct
+func NewBSONMapperStructE(s interface{}) (*StructToBSON, error) {
+	v, err := structValE(s)
+	if err != nil {
+		return nil, err
+	}
+	return &StructToBSON{
+		raw:     s,
+		value:   v,
+		TagName: DefaultTagName,
+	}, nil
+}
+
 // SetTagName sets the tag name to be parsed
 func (s *StructToBSON) SetTagName(tag string) {
 	s.TagName = tag
@@ -98,111 +403,787 @@ func (s *StructToBSON) SetTagName(tag string) {
 // The following tag options are factored into the parsing:
 //
 // 	 // "omitempty" - Omit if the value is the zero value
+// 	 // "keepempty" - Keep the field even if it's the zero value when GenerateFilterOrPatch is set (ie. to match on false)
 // 	 // "omitnested" - Pass the value of the struct directly as opposed to recursively mapping the struct
+// 	 // "resettags" - Map the nested data structure using the DefaultTagName, rather than inheriting the tag configuration (ie. FallbackTagName)
 // 	 // "flatten" - Pull out the data from the nested struct up one level
-// 	 // "string" - Use the implementation of the Stringer interface for the value
+// 	 // "inline" - Pull out the data from the nested struct or map up one level, any other value is stored under it's key as normal
+// 	 // "string" - Use the implementation of the Stringer interface for the value (or the TimeLayout for a time.Time), or convert a []rune to a string
+// 	 // "stringkey=key" - Also set the Stringer value under the key, or only under the key if combined with "string"
+// 	 // "json" - Use the implementation of the json.Marshaler interface for the value, storing the decoded JSON
+// 	 // "oneof=a b c" - Return an error if the string or integer field doesn't hold one of the space separated values
+// 	 // "required" - Return an error if the field holds a zero value (including a nil pointer or interface)
+// 	 // "encoder=name" - Store the value returned by the encoder registered under the name (see RegisterNamedEncoder)
+// 	 // "timestamp" - Convert a time.Time or uint64 value to a primitive.Timestamp
+// 	 // "objectid" - Convert a hex string or 12 byte slice to a primitive.ObjectID
+// 	 // "ref=collection" - Store the id held by the field as a DBRef to the collection, ie. { "$ref": collection, "$id": id }
+// 	 // "char" - Convert a rune (int32) to a single character string
+// 	 // "numify" - Convert a numeric string to an int64, or a float64 if it isn't an integer (see StrictNumify)
+// 	 // "hash=algo" - Store the hex digest of a string rather than the plaintext (md5, sha1, sha256 or sha512)
+// 	 // "hex" - Convert a byte slice or array to a lowercase hex string
+// 	 // "base64", "base64url" - Convert a byte slice or array to a standard or URL safe base64 string (hex > base64 > base64url if more than one is set)
+// 	 // "trim" - Trim any leading or trailing whitespace from a string, before checking whether it's empty
+// 	 // "lower", "upper" - Convert a string to lower or upper case, before checking whether it's empty
+// 	 // "round=N" - Round a float to N decimal places
+// 	 // "minsize" - Store an integer as an int32 if it fits within 32 bits, the same as the Mongo-Go Driver
+// 	 // "unwrap" - If the nested struct maps to a single key, hold that key's value directly
+// 	 // "sparse" - Drop any nil elements (ie. nil pointers) from a slice or array
+// 	 // "keyby=field" - Convert a slice of structs into a document keyed by the value of each struct's field
+// 	 // "tolist=field" - Convert a map of structs into a slice, holding each struct's map key under the field
+// 	 // "len=field" - Store the length of the slice, array, map or string field (referenced by it's name or key) on an integer field
+// 	 // "shardkey" - Place the field after "_id" at the front of ordered output (see ToBSOND)
+// 	 // "when=flagName" - Only include the field if the MappingOpts Conditions[flagName] is true
+// 	 // "group=key" - Nest the field (under it's own key) within a document held under the group's key
+// 	 // "immutable" - Place the field in the immutable map when splitting (see SplitMutableImmutable)
+// 	 // "unset", "push", "pull", "inc", "max", "min", "bit=operation", "currentdate" - Route the field into an update operator (see ToUpdateOperators)
+// 	 // "arrayfilter" or "arrayfilter=identifier" - Targets the elements of an array field with a positional operator in update documents (see ToUpdateOperators)
 // 	 // "-" - Do not map this field
 //
+// If a keyed tag option is repeated (ie. "group=a,group=b"), the first value is used.
+//
+// If multiple fields resolve to the same key, explicit fields take precedence over
+// keys pulled out by "inline", which take precedence over keys pulled out by "flatten"
 func ConvertStructToBSONMap(s interface{}, opts *MappingOpts) bson.M {
-	if reflect.ValueOf(s).Kind() != reflect.Struct && !(reflect.ValueOf(s).Kind() == reflect.Ptr && reflect.ValueOf(s).Elem().Kind() == reflect.Struct) {
+	out, _ := ConvertStructToBSONMapE(s, opts)
+	if len(out) == 0 {
 		return nil
 	}
-	return NewBSONMapperStruct(s).ToBSONMap(opts)
+	return out
+}
+
+// ConvertStructToBSONMapE behaves the same as ConvertStructToBSONMap, however
+// rather than silently returning nil it returns an error if the struct can't be mapped
+// (ie. it isn't a struct, or one of the resolved keys isn't valid)
+//
+// If every field was omitted an empty (but non-nil) bson.M is returned, so that an
+// empty result can be told apart from a struct which couldn't be mapped
+func ConvertStructToBSONMapE(s interface{}, opts *MappingOpts) (bson.M, error) {
+	if err := checkStruct(s); err != nil {
+		return nil, err
+	}
+
+	out, err := NewBSONMapperStruct(s).ToBSONMapE(opts)
+	if err == nil && out == nil {
+		out = bson.M{}
+	}
+	return out, err
 }
 
 // ToBSONMap parses all struct fields and returns a bson.M { tagName: value }.
 // If there are nested structs it calls recursively maps them as well
 func (s *StructToBSON) ToBSONMap(opts *MappingOpts) bson.M {
-	out := bson.M{}
+	out, _ := s.ToBSONMapE(opts)
+	return out
+}
+
+// ToBSONMapE behaves the same as ToBSONMap, however it returns an error
+// if the struct can't be safely mapped
+func (s *StructToBSON) ToBSONMapE(opts *MappingOpts) (bson.M, error) {
+	opts = withDefaults(opts)
+	doc, err := s.topLevelDoc(opts)
+	if err != nil || doc == nil {
+		return nil, err
+	}
 
-	fields := s.structFields()
+	if opts != nil && opts.RootKey != "" {
+		if err := validateKey(opts.RootKey, opts); err != nil {
+			return nil, err
+		}
+
+		inner := make(bson.M, len(doc))
+		for _, e := range doc {
+			inner[e.Key] = e.Value
+		}
+		s.releaseDoc(doc)
+		out := s.newMap()
+		out[opts.RootKey] = inner
+		return out, nil
+	}
+
+	out := s.newMap()
+	for _, e := range doc {
+		out[e.Key] = e.Value
+	}
+	s.releaseDoc(doc)
+	return out, nil
+}
+
+// topLevelDoc maps the struct and then applies any of the
+// options which only apply to the top level document
+func (s *StructToBSON) topLevelDoc(opts *MappingOpts) (bson.D, error) {
+	out, err := s.toBSONDoc(opts)
+	if err != nil || opts == nil {
+		return out, err
+	}
+
+	if len(opts.ContextFields) > 0 {
+		keys := make([]string, 0, len(opts.ContextFields))
+		for k := range opts.ContextFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if err := validateKey(k, opts); err != nil {
+				return nil, err
+			}
+			out = setElem(out, k, opts.ContextFields[k])
+		}
+	}
+
+	if opts.GenerateIDIfMissing && !opts.RemoveID && !hasID(out) {
+		out = setElem(out, "_id", opts.newID())
+	}
+
+	if opts.ValidateEncodable {
+		if err := validateEncodable("", out); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ContentHashKey != "" {
+		if err := validateKey(opts.ContentHashKey, opts); err != nil {
+			return nil, err
+		}
+
+		hash, err := contentHash(out)
+		if err != nil {
+			return nil, err
+		}
+		out = setElem(out, opts.ContentHashKey, hash)
+	}
+	return out, nil
+}
+
+// newID returns a new ObjectID, using the IDGenerator if one has been set
+func (opts *MappingOpts) newID() primitive.ObjectID {
+	if opts.IDGenerator != nil {
+		return opts.IDGenerator()
+	}
+	return primitive.NewObjectID()
+}
+
+// isOpaque checks whether the type (or the type it points to) is one of the OpaqueTypes
+func (opts *MappingOpts) isOpaque(t reflect.Type) bool {
+	if opts == nil {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, opaque := range opts.OpaqueTypes {
+		if t == opaque || (opaque.Kind() == reflect.Ptr && t == opaque.Elem()) {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenKind checks whether the value is one of the ForbiddenKinds, or holds one of them
+// through a pointer, interface or as the elements of a slice, array or map, and returns the forbidden kind
+func (opts *MappingOpts) forbiddenKind(val reflect.Value) (reflect.Kind, bool) {
+	if opts == nil || len(opts.ForbiddenKinds) == 0 {
+		return reflect.Invalid, false
+	}
+	return opts.forbiddenValue(val, make(map[reflect.Type]bool))
+}
+
+// forbiddenValue checks the type of the value, then the values held within it which
+// aren't known from it's type (ie. the elements of a []interface{})
+func (opts *MappingOpts) forbiddenValue(val reflect.Value, seen map[reflect.Type]bool) (reflect.Kind, bool) {
+	if !val.IsValid() {
+		return reflect.Invalid, false
+	}
+	if kind, ok := opts.forbiddenType(val.Type(), seen); ok {
+		return kind, true
+	}
+
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !val.IsNil() {
+			return opts.forbiddenValue(val.Elem(), seen)
+		}
+	case reflect.Slice, reflect.Array:
+		if holdsValues(val.Type().Elem()) {
+			for i := 0; i < val.Len(); i++ {
+				if kind, ok := opts.forbiddenValue(val.Index(i), seen); ok {
+					return kind, true
+				}
+			}
+		}
+	case reflect.Map:
+		if holdsValues(val.Type().Elem()) {
+			iter := val.MapRange()
+			for iter.Next() {
+				if kind, ok := opts.forbiddenValue(iter.Value(), seen); ok {
+					return kind, true
+				}
+			}
+		}
+	}
+	return reflect.Invalid, false
+}
+
+// forbiddenType checks whether the type is one of the ForbiddenKinds, or is a pointer,
+// slice, array or map of one. Structs aren't followed as their fields are checked as they're mapped
+func (opts *MappingOpts) forbiddenType(t reflect.Type, seen map[reflect.Type]bool) (reflect.Kind, bool) {
+	// Recursive types (ie. type list []list) are only checked once
+	if seen[t] {
+		return reflect.Invalid, false
+	}
+	seen[t] = true
+
+	for _, forbidden := range opts.ForbiddenKinds {
+		if t.Kind() == forbidden {
+			return forbidden, true
+		}
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return opts.forbiddenType(t.Elem(), seen)
+	}
+	return reflect.Invalid, false
+}
+
+// holdsValues checks whether values of the type can hold values which
+// aren't known from the type itself, so have to be checked individually
+func holdsValues(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// toBSONMap recursively maps the struct into a bson.M
+func (s *StructToBSON) toBSONMap(opts *MappingOpts) (bson.M, error) {
+	doc, err := s.toBSONDoc(opts)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+
+	out := s.newMap()
+	for _, e := range doc {
+		out[e.Key] = e.Value
+	}
+	return out, nil
+}
+
+// toBSONDoc recursively maps the struct into a bson.D, in the order the fields are declared.
+// Flat structs of scalars take the fast path when generating a filter (see scalarDoc)
+func (s *StructToBSON) toBSONDoc(opts *MappingOpts) (bson.D, error) {
+	if out, ok, err := s.scalarDoc(opts); ok {
+		return out, err
+	}
+	return s.mapFields(opts)
+}
+
+// mapFields recursively maps the struct into a bson.D, in the order the fields are declared.
+// This is where the bulk of the mapping logic lives, any logic which should only be applied
+// to the top level document sits in ToBSONMapE
+func (s *StructToBSON) mapFields(opts *MappingOpts) (bson.D, error) {
+	fields := s.structFields(opts)
+
+	out := s.newDoc()
+	if out == nil {
+		out = make(bson.D, 0, len(fields))
+	}
+
+	// The precedence of any keys which have been promoted from nested data structures
+	var promoted map[string]int
 
 	for _, field := range fields {
+		if opts != nil && opts.SkipPointerFields && field.Type.Kind() == reflect.Ptr {
+			s.skip(field.Name, SkipExcluded)
+			continue
+		}
+
 		name := field.Name
 		val := s.value.FieldByName(name)
 		isSubStruct := false
+		dotted := false
 		var finalVal interface{}
 
 		// Identify whether the struct field has tags or not
-		tagName, tagOpts := parseTag(field.Tag.Get(s.TagName))
+		tagName, tagOpts := parseTag(s.fieldTag(field, opts))
 		if tagName != "" {
 			name = tagName
+		} else {
+			name = opts.defaultKeyCase(name)
+		}
+		name = s.renameKey(name, opts)
+
+		if kind, ok := opts.forbiddenKind(val); ok {
+			return nil, fmt.Errorf("field %q holds the forbidden kind %s: %w", name, kind, ErrForbiddenKind)
+		}
+
+		// Only include conditional fields if their condition has been met
+		if flag, ok := tagOpts.Value("when"); ok && (opts == nil || !opts.Conditions[flag]) {
+			s.skip(field.Name, SkipCondition)
+			continue
+		}
+
+		name, err := resolveKey(name, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		// Fields which share a group are nested together under the group's key
+		group, _ := tagOpts.Value("group")
+		if group != "" {
+			if group, err = resolveKey(group, opts); err != nil {
+				return nil, err
+			}
+		}
+
+		// Computed length fields hold the length of the field they reference
+		if ref, ok := tagOpts.Value("len"); ok {
+			if val, err = s.lengthOf(ref, field.Type, opts); err != nil {
+				return nil, fmt.Errorf("field %q can't hold the length of %q: %w", name, ref, err)
+			}
+		}
+
+		// Strings are transformed (ie. trimmed) before anything else, so that the transformed string is what's checked
+		for _, t := range stringTransforms {
+			if tagOpts.Has(t.opt) {
+				val = transformString(val, t.fn)
+			}
+		}
+
+		// Required fields must hold a value, including nested structs (ie. a nil pointer to a struct)
+		if tagOpts.Has("required") && (!val.IsValid() || val.IsZero()) {
+			return nil, fmt.Errorf("field %q is required but holds a zero value: %w", name, ErrMissingRequired)
 		}
 
 		if opts != nil && tagName == "_id" {
-			if opts.UseIDifAvailable && val.Interface() != "" {
-				return bson.M{"_id": val.Interface()}
+			if id := interfaceOf(val); opts.UseIDifAvailable && id != nil && id != "" {
+				return bson.D{{Key: "_id", Value: opts.coerceObjectID(id)}}, nil
 			}
 			if opts.RemoveID {
+				s.skip(field.Name, SkipExcluded)
 				continue
 			}
 		}
 
-		// Decide whether to omit the field if it is empty or not
-		if tagOpts.Has("omitempty") || (opts != nil && opts.GenerateFilterOrPatch) {
+		// Decide whether to omit the field if it is empty or not, empty strings
+		// can be kept in filters unless the field is flagged with "omitempty"
+		keepEmptyString := opts != nil && opts.KeepEmptyStrings && val.Kind() == reflect.String && val.Len() == 0
+		omitEmpty := tagOpts.Has("omitempty") || (opts != nil && opts.GenerateFilterOrPatch && !keepEmptyString && !tagOpts.Has("keepempty"))
+		if omitEmpty {
+			// Empty strings can be explicitly stored as null rather than being omitted
+			if opts != nil && opts.EmptyStringAsNull && val.Kind() == reflect.String && val.Len() == 0 {
+				out = s.setField(out, group, field.Name, name, primitive.Null{})
+				continue
+			}
 
-			if val.IsZero() {
+			if isEmpty(val, opts) {
+				s.skip(field.Name, SkipEmpty)
 				continue
 			}
+		}
 
-			// Handling edge cases that reflect.value.IsZero doesn't catch
-			switch val.Kind() {
-			case reflect.Slice:
-				if val.Len() == 0 {
-					continue
-				}
-			case reflect.Map:
-				if len(val.MapKeys()) == 0 {
-					continue
-				}
+		// Enum fields must hold one of their allowed values, unless they've been omitted
+		if allowed, ok := tagOpts.Value("oneof"); ok {
+			if err := checkOneOf(val, allowed); err != nil {
+				return nil, fmt.Errorf("field %q %w", name, err)
 			}
 		}
 
-		// If nested data structures should not be omitted
-		if !tagOpts.Has("omitnested") {
-			finalVal = s.nestedData(val, opts)
+		// Anonymous embedded structs without an explicit key are inlined, unless
+		// EmbeddedAsSubdocument is set, in which case they're nested under their type's name
+		embedded := field.Anonymous && tagName == "" && (opts == nil || !opts.EmbeddedAsSubdocument)
 
-			v := reflect.ValueOf(val.Interface())
+		// If the field should be stored as it's JSON representation, decode the JSON
+		if m, ok := jsonMarshaler(val, tagOpts, opts); ok {
+			if finalVal, err = jsonValue(m, opts); err != nil {
+				return nil, fmt.Errorf("unable to convert %q to JSON: %w", name, err)
+			}
+			_, isSubStruct = finalVal.(bson.M)
+		} else if m, ok := bsonMarshaler(val); ok {
+			// Types which marshal themselves are stored as the document they marshal to
+			if finalVal, err = bsonValue(m); err != nil {
+				return nil, fmt.Errorf("unable to marshal %q to BSON: %w", name, err)
+			}
+			isSubStruct = isDoc(finalVal)
+		} else if isBSONNull(val) {
+			// An explicit BSON null is a leaf value, which is stored as null
+			finalVal = primitive.Null{}
+		} else if isDriverLeaf(val) {
+			// Types the driver has dedicated support for are passed through as they are,
+			// including when they're held within an interface (ie. an ObjectID isn't a [12]byte)
+			finalVal = opts.leafValue(val)
+		} else if !tagOpts.Has("omitnested") && !opts.isOpaque(field.Type) {
+			// If nested data structures should not be omitted
+			v := reflect.ValueOf(interfaceOf(val))
 			if v.Kind() == reflect.Ptr {
 				v = v.Elem()
 			}
 
+			// Nested structs inherit the tag configuration of this struct, unless it's reset at this field
+			parent := s
+			if tagOpts.Has("resettags") {
+				parent = s.withResetTags()
+			}
+
+			// Structs which are promoted into this document are mapped in order,
+			// so that their keys keep the order they're declared in
+			if v.Kind() == reflect.Struct && (tagOpts.Has("inline") || tagOpts.Has("flatten") || embedded) {
+				finalVal, err = parent.nestedDoc(val, opts)
+			} else {
+				finalVal, err = parent.nestedData(val, opts)
+			}
+			if err != nil {
+				return nil, err
+			}
+
 			switch v.Kind() {
 			case reflect.Map, reflect.Struct:
 				isSubStruct = true
+				dotted = opts != nil && opts.UseDotNotation
+			case reflect.Slice, reflect.Array:
+				dotted = opts != nil && opts.UseDotNotation
+			}
+
+			// If every field within the nested struct was omitted, then it's empty as well
+			if omitEmpty && v.Kind() == reflect.Struct && !isDoc(finalVal) && parent.hasStructFields(v, opts) {
+				s.skip(field.Name, SkipEmpty)
+				continue
 			}
 		} else {
-			finalVal = val.Interface()
+			finalVal = interfaceOf(val)
 		}
 
-		// If the field should be a string, convert it to a string
-		if tagOpts.Has("string") {
-			s, ok := val.Interface().(fmt.Stringer)
+		// If the field should be a string, convert it to a string. If it has a "stringkey" the string
+		// is set under that key instead, alongside the raw value unless it's also flagged with "string"
+		stringKey, hasStringKey := tagOpts.Value("stringkey")
+		if tagOpts.Has("string") || hasStringKey {
+			if !hasStringKey {
+				stringKey = name
+			} else if stringKey == "" {
+				return nil, fmt.Errorf("field %q has an empty stringkey: %w", name, ErrInvalidKey)
+			} else if stringKey, err = resolveKey(stringKey, opts); err != nil {
+				return nil, err
+			}
+
+			if str, ok := opts.stringify(val); ok {
+				str, err := limitString(stringKey, str, opts)
+				if err != nil {
+					return nil, err
+				}
+				out = s.setField(out, group, field.Name, stringKey, str)
+			}
+
+			if tagOpts.Has("string") {
+				continue
+			}
+		}
+
+		// If the field should be passed through a registered encoder, store the value it encodes to
+		if encoder, ok := tagOpts.Value("encoder"); ok {
+			encode, err := namedEncoder(encoder)
+			if err != nil {
+				return nil, fmt.Errorf("unable to encode %q: %w", name, err)
+			}
+			out = s.setField(out, group, field.Name, name, encode(interfaceOf(val)))
+			continue
+		}
+
+		// If the field should be a BSON timestamp, convert it to a timestamp
+		if tagOpts.Has("timestamp") {
+			if ts, ok := toTimestamp(interfaceOf(val)); ok {
+				out = s.setField(out, group, field.Name, name, ts)
+				continue
+			}
+		}
+
+		// If the field holds the id of a document in another collection, it's stored as a DBRef
+		collection, isRef := tagOpts.Value("ref")
+		if isRef && collection == "" {
+			return nil, fmt.Errorf("field %q references an empty collection name: %w", name, ErrInvalidRef)
+		}
+
+		// If the field should be an ObjectID, coerce it to an ObjectID
+		if tagOpts.Has("objectid") {
+			id, err := toObjectID(val)
+			if err != nil {
+				return nil, fmt.Errorf("unable to convert %q to an ObjectID: %w", name, err)
+			}
+			if isRef {
+				id = toDBRef(collection, id)
+			}
+			out = s.setField(out, group, field.Name, name, id)
+			continue
+		}
+
+		if isRef {
+			out = s.setField(out, group, field.Name, name, toDBRef(collection, finalVal))
+			continue
+		}
+
+		// A hex string "_id" (ie. decoded from JSON) can be stored as the ObjectID it holds
+		if tagName == "_id" {
+			finalVal = opts.coerceObjectID(finalVal)
+		}
+
+		// If the rune should be stored as a single character string, convert it
+		if tagOpts.Has("char") {
+			if str, ok := toChar(val); ok {
+				out = s.setField(out, group, field.Name, name, str)
+				continue
+			}
+		}
+
+		// If the numeric string should be stored as a native number, parse it
+		if tagOpts.Has("numify") {
+			num, ok, err := toNumber(val)
+			switch {
+			case err != nil && opts != nil && opts.StrictNumify:
+				return nil, fmt.Errorf("unable to convert %q to a number: %w", name, err)
+			case ok && err == nil:
+				out = s.setField(out, group, field.Name, name, num)
+				continue
+			}
+		}
+
+		// If the string should be stored as it's digest rather than the plaintext, hash it
+		if algo, ok := tagOpts.Value("hash"); ok {
+			digest, ok, err := hashString(val, algo)
+			if err != nil {
+				return nil, fmt.Errorf("unable to hash %q: %w", name, err)
+			}
 			if ok {
-				out[name] = s.String()
+				out = s.setField(out, group, field.Name, name, digest)
+				continue
 			}
+		}
+
+		// If the field's bytes should be encoded as a string (ie. "hex"), encode them
+		if str, ok := encodeBytes(val, tagOpts); ok {
+			out = s.setField(out, group, field.Name, name, str)
 			continue
 		}
 
-		// If the nested data objects should be flattened
-		if isSubStruct && (tagOpts.Has("flatten")) {
-			outMap := finalVal.(primitive.M)
-			for k := range finalVal.(primitive.M) {
-				out[k] = outMap[k]
+		if finalVal, err = limitString(name, finalVal, opts); err != nil {
+			return nil, err
+		}
+
+		// If the integer should be stored in it's smallest size, convert it to an int32 if it fits
+		if tagOpts.Has("minsize") {
+			finalVal = minSize(finalVal)
+		}
+
+		// If the float should be rounded, round it to the number of decimal places
+		if places, ok := tagOpts.Value("round"); ok {
+			n, err := strconv.Atoi(places)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("field %q can't be rounded to %q decimal places: %w", name, places, ErrInvalidPrecision)
+			}
+			finalVal = roundFloat(finalVal, n)
+		}
+
+		// If the nested struct maps to a single key, it can be collapsed to that key's value
+		if tagOpts.Has("unwrap") && isDoc(finalVal) {
+			if elems := promotedElems(finalVal); len(elems) == 1 {
+				finalVal = elems[0].Value
+				isSubStruct = isDoc(finalVal)
+			}
+		}
+
+		// If the slice should be sparse, drop any nil elements from it
+		if tagOpts.Has("sparse") {
+			finalVal = dropNilElems(finalVal)
+		}
+
+		// If the slice's values should be unique, drop any duplicates from it
+		if opts != nil && opts.DedupeSlices {
+			finalVal = dedupeElems(finalVal)
+		}
+
+		// If the map of structs should be a list, convert it to a slice
+		if field, ok := tagOpts.Value("tolist"); ok {
+			if field, err = resolveKey(field, opts); err != nil {
+				return nil, err
+			}
+			if finalVal, err = toList(name, finalVal, field); err != nil {
+				return nil, err
+			}
+			isSubStruct = false
+		}
+
+		// If the slice of structs should be keyed by one of their fields, convert it to a map
+		if field, ok := tagOpts.Value("keyby"); ok {
+			if finalVal, err = keyBy(name, finalVal, field, opts); err != nil {
+				return nil, err
+			}
+			isSubStruct = true
+		}
+
+		inline := tagOpts.Has("inline")
+		if embedded {
+			if val.Kind() == reflect.Ptr && val.IsNil() {
+				s.skip(field.Name, SkipEmpty)
+				continue
+			}
+			if isDoc(finalVal) {
+				inline = true
+			} else if v := reflect.Indirect(val); v.Kind() == reflect.Struct && s.hasStructFields(v, opts) {
+				// Every field within the embedded struct was omitted, so there is nothing to inline
+				s.skip(field.Name, SkipEmpty)
+				continue
+			}
+		}
+
+		// If the nested document should be flattened, it's elements are set under their dot separated paths
+		var dotElems bson.D
+		if dotted {
+			dotElems = dottedElems(finalVal)
+		}
+
+		// If the nested data objects should be promoted into this document, the keys are
+		// set based on their precedence: explicit fields > "inline" > "flatten". Maps without
+		// string keys can't be promoted, so they're set under their own key instead
+		if isSubStruct && (inline || tagOpts.Has("flatten")) && canPromote(finalVal) {
+			precedence := flattenPrecedence
+			if inline {
+				precedence = inlinePrecedence
+			}
+
+			if promoted == nil {
+				promoted = make(map[string]int)
+			}
+
+			// The keys of inlined maps can be normalised, while the keys of typed fields keep their casing
+			lowercase := inline && opts != nil && opts.LowercaseInlinedMapKeys &&
+				reflect.Indirect(reflect.ValueOf(interfaceOf(val))).Kind() == reflect.Map
+
+			for _, e := range promotedElems(finalVal) {
+				if lowercase {
+					e.Key = strings.ToLower(e.Key)
+				}
+
+				if group == "" {
+					p, wasPromoted := promoted[e.Key]
+					if (wasPromoted && p > precedence) || (!wasPromoted && hasElem(out, e.Key)) {
+						continue
+					}
+					promoted[e.Key] = precedence
+				}
+				out = s.setField(out, group, field.Name, e.Key, e.Value)
+			}
+		} else if len(dotElems) > 0 {
+			// The nested document has already been flattened, so only it's keys need to be prefixed
+			for _, e := range dotElems {
+				delete(promoted, joinPath(name, e.Key))
+				out = s.setField(out, group, field.Name, joinPath(name, e.Key), e.Value)
 			}
 		} else {
-			out[name] = finalVal
+			delete(promoted, name)
+			out = s.setField(out, group, field.Name, name, finalVal)
 		}
 	}
 	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// setField sets the value of the key (see setGroupedElem) and records the name of the field
+// it was mapped from, if the field names are being reported
+func (s *StructToBSON) setField(doc bson.D, group string, fieldName string, key string, val interface{}) bson.D {
+	if s.fieldNames != nil {
+		s.fieldNames[joinPath(group, key)] = fieldName
+	}
+	return setGroupedElem(doc, group, key, val)
+}
+
+// newMap returns the empty map that the struct is mapped into, taking
+// it from the Mapper's pool if there is one
+func (s *StructToBSON) newMap() bson.M {
+	if s.pool != nil {
+		return s.pool.Get().(bson.M)
+	}
+	return bson.M{}
+}
+
+// newDoc returns the empty document that the struct's fields are mapped into, taking
+// it from the Mapper's pool if there is one
+func (s *StructToBSON) newDoc() bson.D {
+	if s.docPool == nil || s.doc != nil {
 		return nil
 	}
-	return out
+	s.doc = s.docPool.Get().(*bson.D)
+	return *s.doc
+}
+
+// releaseDoc clears the document taken from the Mapper's pool once it's elements have been
+// copied into the map, and returns it to the pool. If the document grew beyond the pooled
+// one, the larger document is kept instead
+func (s *StructToBSON) releaseDoc(doc bson.D) {
+	if s.doc == nil {
+		return
+	}
+
+	if cap(doc) > cap(*s.doc) {
+		*s.doc = doc
+	}
+	d := (*s.doc)[:cap(*s.doc)]
+	for i := range d {
+		d[i] = bson.E{}
+	}
+	*s.doc = d[:0]
+	s.docPool.Put(s.doc)
+	s.doc = nil
+}
+
+// nestedStruct wraps a struct which is nested within the struct being mapped
+func (s *StructToBSON) nestedStruct(val reflect.Value, opts *MappingOpts) *StructToBSON {
+	n := NewBSONMapperStruct(val.Interface())
+	n.TagName = s.tagNameFor(n.value.Type(), opts)
+	n.rootTagName = s.globalTagName()
+	n.resetTags = s.resetTags
+	n.nested = true
+	return n
+}
+
+// withResetTags returns a copy of the struct whose nested structs are mapped using the DefaultTagName,
+// rather than inheriting it's tag configuration (although TagNameByType still applies)
+func (s *StructToBSON) withResetTags() *StructToBSON {
+	n := *s
+	n.rootTagName = DefaultTagName
+	n.resetTags = true
+	return &n
+}
+
+// nestedDoc maps a nested struct into a bson.D, preserving the order its fields are declared in.
+// If all of its fields are omitted, the value of the struct is returned as is (the same as nestedData)
+func (s *StructToBSON) nestedDoc(val reflect.Value, opts *MappingOpts) (interface{}, error) {
+	doc, err := s.nestedStruct(val, opts).toBSONDoc(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(doc) == 0 {
+		return val.Interface(), nil
+	}
+	return doc, nil
 }
 
 // nestedData identifies the nested data type and iterates over it
 // to return a BSON map for the nested data structure
-func (s *StructToBSON) nestedData(val reflect.Value, opts *MappingOpts) interface{} {
+func (s *StructToBSON) nestedData(val reflect.Value, opts *MappingOpts) (interface{}, error) {
+	// An invalid value (ie. from a nil interface) holds no data
+	if !val.IsValid() {
+		return nil, nil
+	}
+
+	if m, ok := bsonMarshaler(val); ok {
+		return bsonValue(m)
+	}
+
+	if isDriverLeaf(val) {
+		return opts.leafValue(val), nil
+	}
+
 	var finalVal interface{}
 	v := reflect.ValueOf(val.Interface())
 
@@ -213,9 +1194,20 @@ func (s *StructToBSON) nestedData(val reflect.Value, opts *MappingOpts) interfac
 
 	switch v.Kind() {
 	case reflect.Struct:
-		n := NewBSONMapperStruct(val.Interface())
-		n.TagName = s.TagName
-		m := n.ToBSONMap(opts)
+		if opts.isOpaque(v.Type()) {
+			finalVal = val.Interface()
+			break
+		}
+
+		// Ordered output keeps the order of the nested struct's fields as well
+		if opts != nil && opts.orderedNested {
+			return s.nestedDoc(val, opts)
+		}
+
+		m, err := s.nestedStruct(val, opts).toBSONMap(opts)
+		if err != nil {
+			return nil, err
+		}
 
 		if len(m) == 0 {
 			finalVal = val.Interface()
@@ -225,43 +1217,64 @@ func (s *StructToBSON) nestedData(val reflect.Value, opts *MappingOpts) interfac
 
 	case reflect.Map:
 		// Find the type of the value within the map
-		mapElem := val.Type()
-		switch mapElem.Kind() {
-		case reflect.Ptr, reflect.Array, reflect.Map, reflect.Slice, reflect.Chan:
+		mapElem := v.Type().Elem()
+		if mapElem.Kind() == reflect.Ptr {
 			mapElem = mapElem.Elem()
-			if mapElem.Kind() == reflect.Ptr {
-				mapElem = mapElem.Elem()
-			}
 		}
 
 		// If we need to iterate over some form of struct in the map
 		// ie. map[string]struct
 		if mapElem.Kind() == reflect.Struct || (mapElem.Kind() == reflect.Slice && mapElem.Elem().Kind() == reflect.Struct) {
 			m := bson.M{}
-			for _, k := range val.MapKeys() {
-				m[k.String()] = s.nestedData(val.MapIndex(k), opts)
+			for _, k := range v.MapKeys() {
+				key, err := resolveMapKey(k.String(), opts)
+				if err != nil {
+					return nil, err
+				}
+				elem, err := s.nestedData(v.MapIndex(k), opts)
+				if err != nil {
+					return nil, err
+				}
+
+				// The structs held in the map are flattened along with the map
+				if elems := dottedElems(elem); opts != nil && opts.UseDotNotation && len(elems) > 0 {
+					for _, e := range elems {
+						m[joinPath(key, e.Key)] = e.Value
+					}
+					continue
+				}
+				m[key] = elem
 			}
 			finalVal = m
 			break
 		}
-		finalVal = val.Interface()
 
-	case reflect.Slice, reflect.Array:
-		if val.Type().Kind() == reflect.Ptr {
-			val = val.Elem()
+		// Otherwise the map can be passed as is, as long as all of it's keys are valid
+		m, err := resolveMapKeys(v, opts)
+		if err != nil || opts == nil || !opts.ConvertTimeToDateTime {
+			return m, err
 		}
+		return toDateTimes(m), nil
 
+	case reflect.Slice, reflect.Array:
 		// Ensuring there are no structs (which require further iteration) anywhere within the slice/array
 		// As long as there are not, we just pass the value of the array/slice
-		if val.Type().Elem().Kind() != reflect.Struct && !(val.Type().Elem().Kind() == reflect.Ptr && val.Type().Elem().Elem().Kind() == reflect.Struct) {
-			finalVal = val.Interface()
+		if v.Type().Elem().Kind() != reflect.Struct && !(v.Type().Elem().Kind() == reflect.Ptr && v.Type().Elem().Elem().Kind() == reflect.Struct) {
+			finalVal = v.Interface()
+			if opts != nil && opts.ConvertTimeToDateTime {
+				finalVal = toDateTimes(finalVal)
+			}
 			break
 		}
 
 		// If further iteration is needed, then iterate over the slice
-		slices := make([]interface{}, val.Len())
-		for x := 0; x < val.Len(); x++ {
-			slices[x] = s.nestedData(val.Index(x), opts)
+		slices := make([]interface{}, v.Len())
+		for x := 0; x < v.Len(); x++ {
+			elem, err := s.nestedData(v.Index(x), opts)
+			if err != nil {
+				return nil, err
+			}
+			slices[x] = elem
 		}
 		finalVal = slices
 
@@ -269,5 +1282,5 @@ func (s *StructToBSON) nestedData(val reflect.Value, opts *MappingOpts) interfac
 		finalVal = val.Interface()
 	}
 
-	return finalVal
+	return finalVal, nil
 }
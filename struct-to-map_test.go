@@ -1,12 +1,15 @@
 package mapper
 
 import (
+	"errors"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -28,6 +31,73 @@ var _ = Describe("Utility method", func() {
 		Expect(reflect.ValueOf(result).Elem().Kind()).To(Equal(reflect.Struct))
 	})
 
+	It("NewBSONMapperStructE should return a new wrapped struct", func() {
+		testStruct := struct {
+			TestField1 string
+		}{
+			TestField1: "Test String",
+		}
+
+		result, err := NewBSONMapperStructE(&testStruct)
+		Expect(err).To(BeNil())
+		Expect(result.value.Interface()).To(Equal(testStruct))
+		Expect(result.TagName).To(Equal("bson"))
+	})
+
+	DescribeTable("NewBSONMapperStructE should return an error rather than panicking if",
+		func(c interface{}, kind string) {
+			result, err := NewBSONMapperStructE(c)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrNotAStruct)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring(kind)))
+		},
+		Entry("a slice is passed", []int{1, 2, 3}, "slice"),
+		Entry("a map is passed", map[string]int{}, "map"),
+		Entry("a string is passed", "Test String", "string"),
+		Entry("a pointer to a slice is passed", &[]int{}, "ptr"),
+		Entry("nil is passed", nil, "invalid"),
+	)
+
+	It("NewBSONMapperStruct should still panic if a struct isn't passed", func() {
+		Expect(func() { NewBSONMapperStruct("Test String") }).To(PanicWith("not struct"))
+		Expect(func() { NewBSONMapperStruct((*struct{})(nil)) }).To(PanicWith("not struct"))
+	})
+
+	It("NewBSONMapperStructE should return a distinct error if a nil pointer to a struct is passed", func() {
+		type nilStruct struct{}
+		result, err := NewBSONMapperStructE((*nilStruct)(nil))
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrNilStruct)).To(BeTrue())
+		Expect(errors.Is(err, ErrNotAStruct)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("nil *mapper.nilStruct")))
+	})
+
+	It("ConvertStructToBSONMapE should return an error wrapping ErrNotAStruct", func() {
+		result, err := ConvertStructToBSONMapE([]int{1}, nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrNotAStruct)).To(BeTrue())
+		Expect(err).To(MatchError("expected struct or pointer to struct, got slice: not a struct"))
+	})
+
+	It("ConvertStructToBSONMapE should return an empty map if every field was omitted", func() {
+		testStruct := struct {
+			Name string `bson:"name,omitempty"`
+		}{}
+		result, err := ConvertStructToBSONMapE(testStruct, nil)
+		Expect(err).To(BeNil())
+		Expect(result).NotTo(BeNil())
+		Expect(result).To(BeEmpty())
+		Expect(ConvertStructToBSONMap(testStruct, nil)).To(BeNil())
+	})
+
+	It("ConvertStructToBSONMapE should distinguish a nil pointer from the wrong kind", func() {
+		var testStruct *struct{ Name string }
+		result, err := ConvertStructToBSONMapE(testStruct, nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrNilStruct)).To(BeTrue())
+		Expect(ConvertStructToBSONMap(testStruct, nil)).To(BeNil())
+	})
+
 	It("SetTagName should set a new TagName", func() {
 		testStruct := NewBSONMapperStruct(
 			struct {
@@ -312,201 +382,2950 @@ var _ = Describe("The Mapping functions", func() {
 		)
 	})
 
-	// Testing the functionality of the "string" tag
-	Context("should convert", func() {
-		It("a struct that implements Stringer interface to a string", func() {
-			// Using time.Time as it implements the Stringer interface
+	// Testing the functionality of omitting nested structs which map to an empty document
+	Context("should handle a nested struct which maps to an empty document", func() {
+		type nestedStruct struct {
+			TestField2 string `bson:"testField2,omitempty"`
+			TestField3 int    `bson:"testField3,omitempty"`
+		}
+
+		type parentStruct struct {
+			TestField1 string        `bson:"testField1"`
+			Nested     nestedStruct  `bson:"nested,omitempty"`
+			NestedPtr  *nestedStruct `bson:"nestedPtr,omitempty"`
+		}
+
+		It("by dropping it when flagged with omitempty", func() {
+			result := ConvertStructToBSONMap(
+				parentStruct{
+					TestField1: "Test String",
+					Nested:     nestedStruct{TestField3: 0},
+					NestedPtr:  &nestedStruct{},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"testField1": "Test String"}))
+		})
+
+		It("by dropping it when it becomes empty under GenerateFilterOrPatch", func() {
 			result := ConvertStructToBSONMap(
 				struct {
-					TestField1 time.Time `bson:"testField1,string"`
+					TestField1 string `bson:"testField1"`
+					Nested     struct {
+						TestField2 string `bson:"testField2"`
+						TestField3 bool   `bson:"testField3"`
+					} `bson:"nested"`
 				}{
-					TestField1: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+					TestField1: "Test String",
+				}, &MappingOpts{GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{"testField1": "Test String"}))
+		})
+
+		It("by keeping a partial document", func() {
+			result := ConvertStructToBSONMap(
+				parentStruct{
+					TestField1: "Test String",
+					Nested:     nestedStruct{TestField2: "Test String 2"},
+				}, &MappingOpts{GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{
+				"testField1": "Test String",
+				"nested":     bson.M{"testField2": "Test String 2"},
+			}))
+		})
+
+		It("by not dropping a struct which is mapped as a value", func() {
+			testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 time.Time `bson:"testField1,omitempty"`
+				}{
+					TestField1: testTime,
 				}, nil,
 			)
-			Expect(result).To(Equal(bson.M{"testField1": "2000-01-01 00:00:00 +0000 UTC"}))
+			Expect(result).To(Equal(bson.M{"testField1": testTime}))
 		})
 	})
 
-	// Testing the functionality of nested structs
-	Context("should correctly parse", func() {
-		type valueStruct struct {
-			String string         `bson:"str"`
-			Slice  []string       `bson:"slice"`
-			Map    map[string]int `bson:"map"`
-			Time   time.Time      `bson:"time"`
+	// Testing the functionality of the RenameKeys option
+	Context("should rename keys", func() {
+		type metadata struct {
+			LastActive string `bson:"lastActive"`
 		}
 
-		var valuesStruct valueStruct
+		type user struct {
+			FirstName  string   `bson:"firstName"`
+			LastActive string   `bson:"lastActive"`
+			Metadata   metadata `bson:"metadata"`
+		}
+
+		var testStruct user
 		BeforeEach(func() {
-			valuesStruct = valueStruct{
-				String: "Test String",
-				Slice:  []string{"Test 1", "!@£$%^&*()", ""},
-				Map:    map[string]int{"Test 1": 100, "Test 2!": 0, "Test 3": -100},
-				Time:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			testStruct = user{
+				FirstName:  "Jane",
+				LastActive: "Today",
+				Metadata:   metadata{LastActive: "Yesterday"},
 			}
 		})
 
-		It("a nested struct", func() {
+		It("only in the top level struct by default", func() {
+			result := ConvertStructToBSONMap(testStruct, &MappingOpts{RenameKeys: map[string]string{"lastActive": "lastSeen"}})
+			Expect(result).To(Equal(bson.M{
+				"firstName": "Jane",
+				"lastSeen":  "Today",
+				"metadata":  bson.M{"lastActive": "Yesterday"},
+			}))
+		})
+
+		It("in nested structs when RenameKeysRecursive is set to true", func() {
+			result := ConvertStructToBSONMap(testStruct, &MappingOpts{
+				RenameKeys:          map[string]string{"lastActive": "lastSeen"},
+				RenameKeysRecursive: true,
+			})
+			Expect(result).To(Equal(bson.M{
+				"firstName": "Jane",
+				"lastSeen":  "Today",
+				"metadata":  bson.M{"lastSeen": "Yesterday"},
+			}))
+		})
+
+		It("and still validate the renamed key", func() {
+			result, err := ConvertStructToBSONMapE(testStruct, &MappingOpts{RenameKeys: map[string]string{"lastActive": "$lastSeen"}})
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the "when" tag
+	Context("should include conditional fields", func() {
+		type featureStruct struct {
+			TestField1 string `bson:"testField1"`
+			TestField2 string `bson:"testField2,when=beta"`
+		}
+
+		testStruct := featureStruct{TestField1: "Test String", TestField2: "Beta String"}
+
+		It("when the condition is true", func() {
+			result := ConvertStructToBSONMap(testStruct, &MappingOpts{Conditions: map[string]bool{"beta": true}})
+			Expect(result).To(Equal(bson.M{"testField1": "Test String", "testField2": "Beta String"}))
+		})
+
+		It("unless the condition is false", func() {
+			result := ConvertStructToBSONMap(testStruct, &MappingOpts{Conditions: map[string]bool{"beta": false}})
+			Expect(result).To(Equal(bson.M{"testField1": "Test String"}))
+		})
+
+		It("unless the condition isn't set", func() {
+			result := ConvertStructToBSONMap(testStruct, nil)
+			Expect(result).To(Equal(bson.M{"testField1": "Test String"}))
+		})
+	})
+
+	// Testing the functionality of the "group" tag
+	Context("should group fields", func() {
+		It("which share a group under the group's key", func() {
 			result := ConvertStructToBSONMap(
 				struct {
-					TestField1 string      `bson:"testField1"`
-					TestField2 valueStruct `bson:"nestedStruct"`
+					Name     string `bson:"name"`
+					Street   string `bson:"street,group=address"`
+					City     string `bson:"city,group=address"`
+					Postcode string `bson:"postcode,group=address"`
 				}{
-					TestField1: valuesStruct.String,
-					TestField2: valueStruct{
-						String: valuesStruct.String,
-						Slice:  valuesStruct.Slice,
-						Map:    valuesStruct.Map,
-						Time:   valuesStruct.Time,
-					},
+					Name:     "Jane",
+					Street:   "1 Test Street",
+					City:     "London",
+					Postcode: "SW1A 1AA",
 				}, nil,
 			)
-
-			expected := bson.M{
-				"str":   valuesStruct.String,
-				"slice": valuesStruct.Slice,
-				"map":   valuesStruct.Map,
-				"time":  valuesStruct.Time,
-			}
-
-			Expect(result["testField1"]).To(Equal(valuesStruct.String))
-			for k, _ := range result["nestedStruct"].(bson.M) {
-				Expect(result["nestedStruct"].(bson.M)[k]).To(Equal(expected[k]))
-			}
+			Expect(result).To(Equal(bson.M{
+				"name": "Jane",
+				"address": bson.M{
+					"street":   "1 Test Street",
+					"city":     "London",
+					"postcode": "SW1A 1AA",
+				},
+			}))
 		})
 
-		It("a pointer to a nested struct", func() {
+		It("and omit the group if all of it's fields are omitted", func() {
 			result := ConvertStructToBSONMap(
-				&struct {
-					TestField1 string      `bson:"testField1"`
-					TestField2 valueStruct `bson:"nestedStruct"`
+				struct {
+					Name   string `bson:"name"`
+					Street string `bson:"street,group=address"`
+					City   string `bson:"city,group=address"`
 				}{
-					TestField1: valuesStruct.String,
-					TestField2: valueStruct{
-						String: valuesStruct.String,
-						Slice:  valuesStruct.Slice,
-						Map:    valuesStruct.Map,
-						Time:   valuesStruct.Time,
-					},
+					Name: "Jane",
+				}, &MappingOpts{GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{"name": "Jane"}))
+		})
+
+		It("and return an error if the group's key is invalid", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Street string `bson:"street,group=$address"`
+				}{
+					Street: "1 Test Street",
 				}, nil,
 			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the EmptyStringAsNull option
+	Context("should convert empty strings to null", func() {
+		type testStruct struct {
+			TestField1 string `bson:"testField1"`
+			TestField2 string `bson:"testField2"`
+			TestField3 int    `bson:"testField3"`
+		}
+
+		It("when EmptyStringAsNull and GenerateFilterOrPatch are set to true", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{TestField1: "Test String"},
+				&MappingOpts{GenerateFilterOrPatch: true, EmptyStringAsNull: true},
+			)
+			Expect(result).To(Equal(bson.M{"testField1": "Test String", "testField2": primitive.Null{}}))
+		})
 
-			expected := bson.M{
-				"str":   valuesStruct.String,
-				"slice": valuesStruct.Slice,
-				"map":   valuesStruct.Map,
-				"time":  valuesStruct.Time,
-			}
+		It("when EmptyStringAsNull is set to true and the field is flagged with omitempty", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 string `bson:"testField1,omitempty"`
+					TestField2 string `bson:"testField2"`
+				}{},
+				&MappingOpts{EmptyStringAsNull: true},
+			)
+			Expect(result).To(Equal(bson.M{"testField1": primitive.Null{}, "testField2": ""}))
+		})
 
-			Expect(result["testField1"]).To(Equal(valuesStruct.String))
-			for k, _ := range result["nestedStruct"].(bson.M) {
-				Expect(result["nestedStruct"].(bson.M)[k]).To(Equal(expected[k]))
-			}
+		It("unless EmptyStringAsNull is false", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{TestField1: "Test String"},
+				&MappingOpts{GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{"testField1": "Test String"}))
 		})
+	})
 
-		It("a nested struct with the omitnested tag", func() {
+	// Testing the precedence of keys which are pulled up from nested data structures
+	Context("should give precedence to", func() {
+		type inner struct {
+			Name string `bson:"name"`
+			City string `bson:"city"`
+		}
+
+		type other struct {
+			Name    string `bson:"name"`
+			City    string `bson:"city"`
+			Country string `bson:"country"`
+		}
+
+		It("explicit fields over inlined fields declared after them", func() {
 			result := ConvertStructToBSONMap(
 				struct {
-					TestField1 string      `bson:"testField1"`
-					TestField2 valueStruct `bson:"nestedStruct,omitnested"`
+					Name  string `bson:"name"`
+					Inner inner  `bson:"inner,inline"`
 				}{
-					TestField1: valuesStruct.String,
-					TestField2: valueStruct{
-						String: valuesStruct.String,
-						Slice:  valuesStruct.Slice,
-						Map:    valuesStruct.Map,
-						Time:   valuesStruct.Time,
-					},
+					Name:  "Explicit",
+					Inner: inner{Name: "Inline", City: "London"},
 				}, nil,
 			)
+			Expect(result).To(Equal(bson.M{"name": "Explicit", "city": "London"}))
+		})
 
-			expected := bson.M{
-				"testField1": valuesStruct.String,
-				"nestedStruct": valueStruct{
-					String: valuesStruct.String,
-					Slice:  valuesStruct.Slice,
-					Map:    valuesStruct.Map,
-					Time:   valuesStruct.Time,
-				},
-			}
+		It("explicit fields over inlined fields declared before them", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Inner inner  `bson:"inner,inline"`
+					Name  string `bson:"name"`
+				}{
+					Inner: inner{Name: "Inline", City: "London"},
+					Name:  "Explicit",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": "Explicit", "city": "London"}))
+		})
+
+		It("inlined fields over flattened fields, regardless of the order they're declared", func() {
+			expected := bson.M{"name": "Inline", "city": "London", "country": "UK"}
 
+			result := ConvertStructToBSONMap(
+				struct {
+					Other other `bson:"other,flatten"`
+					Inner inner `bson:"inner,inline"`
+				}{
+					Other: other{Name: "Flatten", City: "Paris", Country: "UK"},
+					Inner: inner{Name: "Inline", City: "London"},
+				}, nil,
+			)
+			Expect(result).To(Equal(expected))
+
+			result = ConvertStructToBSONMap(
+				struct {
+					Inner inner `bson:"inner,inline"`
+					Other other `bson:"other,flatten"`
+				}{
+					Inner: inner{Name: "Inline", City: "London"},
+					Other: other{Name: "Flatten", City: "Paris", Country: "UK"},
+				}, nil,
+			)
 			Expect(result).To(Equal(expected))
 		})
 
-		It("a nested struct with the flatten tag", func() {
+		It("explicit fields over inlined map entries", func() {
 			result := ConvertStructToBSONMap(
 				struct {
-					TestField1 string      `bson:"testField1"`
-					TestField2 valueStruct `bson:"nestedStruct,flatten"`
+					Name  string            `bson:"name"`
+					Attrs map[string]string `bson:"attrs,inline"`
 				}{
-					TestField1: valuesStruct.String,
-					TestField2: valueStruct{
-						String: valuesStruct.String,
-						Slice:  valuesStruct.Slice,
-						Map:    valuesStruct.Map,
-						Time:   valuesStruct.Time,
-					},
+					Name:  "Explicit",
+					Attrs: map[string]string{"name": "Inline", "colour": "blue"},
 				}, nil,
 			)
+			Expect(result).To(Equal(bson.M{"name": "Explicit", "colour": "blue"}))
+		})
 
-			expected := bson.M{
-				"testField1": valuesStruct.String,
-				"str":        valuesStruct.String,
-				"slice":      valuesStruct.Slice,
-				"map":        valuesStruct.Map,
-				"time":       valuesStruct.Time,
+		It("explicit fields over inlined map entries, regardless of the order they're declared", func() {
+			type mapFirst struct {
+				Attrs map[string]string `bson:"attrs,inline"`
+				Name  string            `bson:"name"`
 			}
 
-			Expect(result).To(Equal(expected))
-		})
-
-		It("a nested struct with a slice of interfaces", func() {
-			type interfaceStruct struct {
-				TestField3 []interface{} `bson:"interfaces"`
+			type mapLast struct {
+				Name  string            `bson:"name"`
+				Attrs map[string]string `bson:"attrs,inline"`
 			}
 
-			t := []int{1, 2, 3, 4}
-			interfaceSlice := make([]interface{}, len(t))
-			for i, v := range t {
-				interfaceSlice[i] = v
+			attrs := map[string]string{"a": "1", "b": "2", "name": "Inline", "y": "3", "z": "4"}
+			expected := bson.M{"name": "Explicit", "a": "1", "b": "2", "y": "3", "z": "4"}
+
+			// Repeated as the order maps are iterated over is randomised
+			for i := 0; i < 50; i++ {
+				Expect(ConvertStructToBSONMap(mapFirst{Attrs: attrs, Name: "Explicit"}, nil)).To(Equal(expected))
+				Expect(ConvertStructToBSONMap(mapLast{Name: "Explicit", Attrs: attrs}, nil)).To(Equal(expected))
 			}
+		})
 
+		It("the last declared inlined map, when two inlined maps overlap", func() {
 			result := ConvertStructToBSONMap(
 				struct {
-					TestField1 string          `bson:"testField1"`
-					TestField2 interfaceStruct `bson:"nestedStruct"`
+					First  map[string]string `bson:"first,inline"`
+					Second map[string]string `bson:"second,inline"`
 				}{
-					TestField1: valuesStruct.String,
-					TestField2: interfaceStruct{
-						TestField3: interfaceSlice,
-					},
+					First:  map[string]string{"colour": "Red", "size": "L"},
+					Second: map[string]string{"colour": "Blue"},
 				}, nil,
 			)
+			Expect(result).To(Equal(bson.M{"colour": "Blue", "size": "L"}))
+		})
 
-			expected := bson.M{
-				"testField1": valuesStruct.String,
-				"nestedStruct": bson.M{
-					"interfaces": interfaceSlice,
+		It("explicit fields followed by inlined map entries sorted by their key, in ordered output", func() {
+			result := NewBSONMapperStruct(
+				struct {
+					Name  string            `bson:"name"`
+					Attrs map[string]string `bson:"attrs,inline"`
+				}{
+					Name:  "Explicit",
+					Attrs: map[string]string{"c": "3", "a": "1", "b": "2"},
 				},
-			}
+			).ToBSOND(nil)
+			Expect(result).To(Equal(bson.D{
+				{Key: "name", Value: "Explicit"},
+				{Key: "a", Value: "1"},
+				{Key: "b", Value: "2"},
+				{Key: "c", Value: "3"},
+			}))
+		})
 
-			Expect(result).To(Equal(expected))
+		It("explicit fields over every other source, when all three overlap", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Other other  `bson:"other,flatten"`
+					Inner inner  `bson:"inner,inline"`
+					City  string `bson:"city"`
+				}{
+					Other: other{Name: "Flatten", City: "Paris", Country: "France"},
+					Inner: inner{Name: "Inline", City: "London"},
+					City:  "Explicit",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": "Inline", "city": "Explicit", "country": "France"}))
 		})
 	})
 
-	// Testing the functionality of a slice of structs
-	Context("should correctly parse", func() {
-		type valueStruct struct {
-			String string         `bson:"str"`
-			Slice  []string       `bson:"slice"`
-			Map    map[string]int `bson:"map"`
-			Time   time.Time      `bson:"time"`
+	// Testing values which hold no data, and would previously cause reflect to panic
+	Context("should handle invalid reflect values", func() {
+		type inner struct {
+			Name string `bson:"name"`
 		}
 
-		var valuesStruct valueStruct
-		var expectedStruct bson.M
+		It("when nestedData is passed an invalid value", func() {
+			s := NewBSONMapperStruct(struct{}{})
+			Expect(func() {
+				result, err := s.nestedData(reflect.Value{}, &MappingOpts{})
+				Expect(result).To(BeNil())
+				Expect(err).To(BeNil())
+			}).NotTo(Panic())
+		})
 
-		BeforeEach(func() {
+		It("when isNullTime is passed an invalid value", func() {
+			Expect(isNullTime(reflect.Value{}, time.Unix(0, 0))).To(BeFalse())
+		})
+
+		It("when an interface holds a slice of structs", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items interface{} `bson:"items"`
+				}{
+					Items: []inner{{Name: "First"}, {Name: "Second"}},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": []interface{}{bson.M{"name": "First"}, bson.M{"name": "Second"}}}))
+		})
+
+		It("when an interface holds a map of structs", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items interface{} `bson:"items"`
+				}{
+					Items: map[string]inner{"first": {Name: "First"}},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": bson.M{"first": bson.M{"name": "First"}}}))
+		})
+
+		It("when a map holds a nil interface", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items map[string]interface{} `bson:"items"`
+				}{
+					Items: map[string]interface{}{"empty": nil},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": map[string]interface{}{"empty": nil}}))
+		})
+
+		It("when UseIDifAvailable is set and the ID is a nil interface", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					ID   interface{} `bson:"_id"`
+					Name string      `bson:"name"`
+				}{
+					Name: "Test",
+				},
+				&MappingOpts{UseIDifAvailable: true},
+			)
+			Expect(result).To(Equal(bson.M{"_id": nil, "name": "Test"}))
+		})
+	})
+
+	// Testing the functionality of the MaxStringLen option
+	Context("should enforce the maximum string length", func() {
+		type testStruct struct {
+			TestField1 string  `bson:"testField1"`
+			TestField2 *string `bson:"testField2"`
+		}
+
+		long := "Test String"
+
+		It("by returning an error which reports the key when a string exceeds it", func() {
+			result, err := ConvertStructToBSONMapE(testStruct{TestField1: long}, &MappingOpts{MaxStringLen: 4})
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrStringTooLong)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`"testField1"`))
+		})
+
+		It("by returning an error when a pointer to a string exceeds it", func() {
+			result, err := ConvertStructToBSONMapE(testStruct{TestField2: &long}, &MappingOpts{MaxStringLen: 4})
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrStringTooLong)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`"testField2"`))
+		})
+
+		It("by returning an error when a nested string exceeds it", func() {
+			_, err := ConvertStructToBSONMapE(
+				struct {
+					Nested testStruct `bson:"nested"`
+				}{
+					Nested: testStruct{TestField1: long},
+				},
+				&MappingOpts{MaxStringLen: 4},
+			)
+			Expect(errors.Is(err, ErrStringTooLong)).To(BeTrue())
+		})
+
+		It("by truncating strings when TruncateLongStrings is set to true", func() {
+			result, err := ConvertStructToBSONMapE(
+				testStruct{TestField1: long, TestField2: &long},
+				&MappingOpts{MaxStringLen: 4, TruncateLongStrings: true},
+			)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"testField1": "Test", "testField2": "Test"}))
+		})
+
+		It("without splitting a multi-byte character when truncating", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{TestField1: "Tést"},
+				&MappingOpts{MaxStringLen: 2, TruncateLongStrings: true, GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{"testField1": "T"}))
+		})
+
+		DescribeTable("by returning an error which reports the path when a string within a container exceeds it",
+			func(s interface{}, path string) {
+				result, err := ConvertStructToBSONMapE(s, &MappingOpts{MaxStringLen: 4})
+				Expect(result).To(BeNil())
+				Expect(errors.Is(err, ErrStringTooLong)).To(BeTrue())
+				Expect(err.Error()).To(ContainSubstring(path))
+			},
+			Entry("a slice", struct {
+				Tags []string `bson:"tags"`
+			}{Tags: []string{"a", long}}, `"tags.1"`),
+			Entry("an array", struct {
+				Tags [2]string `bson:"tags"`
+			}{Tags: [2]string{long, "a"}}, `"tags.0"`),
+			Entry("a map", struct {
+				Labels map[string]string `bson:"labels"`
+			}{Labels: map[string]string{"name": long}}, `"labels.name"`),
+			Entry("a nested slice", struct {
+				Grid [][]string `bson:"grid"`
+			}{Grid: [][]string{{"a"}, {"b", long}}}, `"grid.1.1"`),
+			Entry("a slice of interfaces", struct {
+				Values []interface{} `bson:"values"`
+			}{Values: []interface{}{1, long}}, `"values.1"`),
+			Entry("a slice of pointers", struct {
+				Tags []*string `bson:"tags"`
+			}{Tags: []*string{&long}}, `"tags.0"`),
+		)
+
+		It("by truncating strings within containers when TruncateLongStrings is set to true, keeping their types", func() {
+			tags := []string{"a", long}
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Tags   []string            `bson:"tags"`
+					Codes  [1]string           `bson:"codes"`
+					Labels map[string]string   `bson:"labels"`
+					Grid   [][]string          `bson:"grid"`
+					Values map[string][]string `bson:"values"`
+				}{
+					Tags:   tags,
+					Codes:  [1]string{long},
+					Labels: map[string]string{"name": long, "id": "a"},
+					Grid:   [][]string{{long}},
+					Values: map[string][]string{"list": {long}},
+				},
+				&MappingOpts{MaxStringLen: 4, TruncateLongStrings: true},
+			)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{
+				"tags":   []string{"a", "Test"},
+				"codes":  [1]string{"Test"},
+				"labels": map[string]string{"name": "Test", "id": "a"},
+				"grid":   [][]string{{"Test"}},
+				"values": map[string][]string{"list": {"Test"}},
+			}))
+			Expect(tags).To(Equal([]string{"a", long}))
+		})
+
+		It("but not byte slices", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Data []byte `bson:"data"`
+			}{Data: []byte(long)}, &MappingOpts{MaxStringLen: 4})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"data": []byte(long)}))
+		})
+
+		It("but not strings within the limit", func() {
+			result, err := ConvertStructToBSONMapE(testStruct{TestField1: long}, &MappingOpts{MaxStringLen: len(long), GenerateFilterOrPatch: true})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"testField1": long}))
+		})
+	})
+
+	// Testing the functionality of the GenerateIDIfMissing option
+	Context("should generate an ID", func() {
+		testID := primitive.NewObjectID()
+		opts := &MappingOpts{
+			GenerateIDIfMissing: true,
+			IDGenerator:         func() primitive.ObjectID { return testID },
+		}
+
+		It("when the struct has no _id field", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Name string `bson:"name"`
+				}{
+					Name: "Test",
+				}, opts,
+			)
+			Expect(result).To(Equal(bson.M{"_id": testID, "name": "Test"}))
+		})
+
+		It("when the _id field holds a zero value", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					ID   primitive.ObjectID `bson:"_id"`
+					Name string             `bson:"name"`
+				}{
+					Name: "Test",
+				}, opts,
+			)
+			Expect(result).To(Equal(bson.M{"_id": testID, "name": "Test"}))
+		})
+
+		It("when the _id field is omitted as it's empty", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					ID   *primitive.ObjectID `bson:"_id,omitempty"`
+					Name string              `bson:"name"`
+				}{
+					Name: "Test",
+				}, opts,
+			)
+			Expect(result).To(Equal(bson.M{"_id": testID, "name": "Test"}))
+		})
+
+		It("using primitive.NewObjectID if no IDGenerator is set", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Name string `bson:"name"`
+				}{
+					Name: "Test",
+				}, &MappingOpts{GenerateIDIfMissing: true},
+			)
+			Expect(result).To(HaveKey("_id"))
+			Expect(result["_id"].(primitive.ObjectID).IsZero()).To(BeFalse())
+		})
+
+		It("but not when the _id field is present", func() {
+			existingID := primitive.NewObjectID()
+			result := ConvertStructToBSONMap(
+				struct {
+					ID   primitive.ObjectID `bson:"_id"`
+					Name string             `bson:"name"`
+				}{
+					ID:   existingID,
+					Name: "Test",
+				}, opts,
+			)
+			Expect(result).To(Equal(bson.M{"_id": existingID, "name": "Test"}))
+		})
+
+		It("but not when RemoveID is set to true", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Name string `bson:"name"`
+				}{
+					Name: "Test",
+				},
+				&MappingOpts{GenerateIDIfMissing: true, RemoveID: true},
+			)
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+
+		It("but not within nested structs", func() {
+			type nested struct {
+				Name string `bson:"name"`
+			}
+
+			result := ConvertStructToBSONMap(
+				struct {
+					ID     primitive.ObjectID `bson:"_id"`
+					Nested nested             `bson:"nested"`
+				}{
+					Nested: nested{Name: "Test"},
+				}, opts,
+			)
+			Expect(result).To(Equal(bson.M{"_id": testID, "nested": bson.M{"name": "Test"}}))
+		})
+	})
+
+	// Testing the functionality of the TagNameByType option
+	Context("should use the tag name for the type", func() {
+		type innermost struct {
+			Value string `bson:"value" db:"db_value"`
+		}
+
+		type inner struct {
+			Street    string    `db:"street_name" bson:"street"`
+			Skipped   string    `db:"-" bson:"skipped"`
+			Innermost innermost `db:"innermost" bson:"innermostBSON"`
+		}
+
+		type outer struct {
+			Name  string `bson:"name" db:"db_name"`
+			Inner inner  `bson:"inner" db:"db_inner"`
+		}
+
+		testStruct := outer{
+			Name: "Test",
+			Inner: inner{
+				Street:    "1 Test Street",
+				Skipped:   "Skipped",
+				Innermost: innermost{Value: "Value"},
+			},
+		}
+
+		It("when mapping nested structs of that type", func() {
+			result := ConvertStructToBSONMap(testStruct, &MappingOpts{
+				TagNameByType: map[reflect.Type]string{reflect.TypeOf(inner{}): "db"},
+			})
+			Expect(result).To(Equal(bson.M{
+				"name": "Test",
+				"inner": bson.M{
+					"street_name": "1 Test Street",
+					"innermost":   bson.M{"value": "Value"},
+				},
+			}))
+		})
+
+		It("falling back to the top level struct's tag name otherwise", func() {
+			result := ConvertStructToBSONMap(testStruct, nil)
+			Expect(result).To(Equal(bson.M{
+				"name": "Test",
+				"inner": bson.M{
+					"street":        "1 Test Street",
+					"skipped":       "Skipped",
+					"innermostBSON": bson.M{"value": "Value"},
+				},
+			}))
+		})
+
+		It("falling back to a custom top level tag name", func() {
+			s := NewBSONMapperStruct(testStruct)
+			s.SetTagName("db")
+			result := s.ToBSONMap(&MappingOpts{
+				TagNameByType: map[reflect.Type]string{reflect.TypeOf(inner{}): "bson"},
+			})
+			Expect(result).To(Equal(bson.M{
+				"db_name": "Test",
+				"db_inner": bson.M{
+					"street":        "1 Test Street",
+					"skipped":       "Skipped",
+					"innermostBSON": bson.M{"db_value": "Value"},
+				},
+			}))
+		})
+	})
+
+	// Testing the functionality of the timestamp tag option
+	Context("should convert to a BSON timestamp", func() {
+		testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		It("when a time.Time field is flagged with timestamp", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					TS  time.Time  `bson:"ts,timestamp"`
+					Ptr *time.Time `bson:"ptr,timestamp"`
+				}{
+					TS:  testTime,
+					Ptr: &testTime,
+				}, nil,
+			)
+			expected := primitive.Timestamp{T: uint32(testTime.Unix())}
+			Expect(result).To(Equal(bson.M{"ts": expected, "ptr": expected}))
+		})
+
+		It("when a uint64 field is flagged with timestamp", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					TS uint64 `bson:"ts,timestamp"`
+				}{
+					TS: 946684800<<32 | 7,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"ts": primitive.Timestamp{T: 946684800, I: 7}}))
+		})
+
+		It("unless the field can't be converted", func() {
+			var nilTime *time.Time
+			result := ConvertStructToBSONMap(
+				struct {
+					TS  string     `bson:"ts,timestamp"`
+					Ptr *time.Time `bson:"ptr,timestamp"`
+				}{
+					TS: "Test String",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"ts": "Test String", "ptr": nilTime}))
+		})
+	})
+
+	// Testing the handling of anonymous embedded structs
+	Context("should handle embedded structs", func() {
+		type Timestamps struct {
+			CreatedAt time.Time `bson:"createdAt"`
+			UpdatedAt time.Time `bson:"updatedAt"`
+		}
+
+		testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		timestamps := Timestamps{CreatedAt: testTime, UpdatedAt: testTime}
+
+		type testStruct struct {
+			Name string `bson:"name"`
+			Timestamps
+		}
+
+		It("by inlining them by default", func() {
+			result := ConvertStructToBSONMap(testStruct{Name: "Test", Timestamps: timestamps}, nil)
+			Expect(result).To(Equal(bson.M{"name": "Test", "createdAt": testTime, "updatedAt": testTime}))
+		})
+
+		It("by nesting them under their type's name when EmbeddedAsSubdocument is set to true", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{Name: "Test", Timestamps: timestamps},
+				&MappingOpts{EmbeddedAsSubdocument: true},
+			)
+			Expect(result).To(Equal(bson.M{
+				"name":       "Test",
+				"Timestamps": bson.M{"createdAt": testTime, "updatedAt": testTime},
+			}))
+		})
+
+		It("by nesting pointers to them under their type's name when EmbeddedAsSubdocument is set to true", func() {
+			type pointerStruct struct {
+				Name string `bson:"name"`
+				*Timestamps
+			}
+
+			result := ConvertStructToBSONMap(pointerStruct{Name: "Test", Timestamps: &timestamps}, &MappingOpts{EmbeddedAsSubdocument: true})
+			Expect(result).To(Equal(bson.M{
+				"name":       "Test",
+				"Timestamps": bson.M{"createdAt": testTime, "updatedAt": testTime},
+			}))
+
+			result = ConvertStructToBSONMap(pointerStruct{Name: "Test"}, &MappingOpts{EmbeddedAsSubdocument: true, GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+
+		It("by nesting them under their type's name in the DefaultKeyCase", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{Name: "Test", Timestamps: timestamps},
+				&MappingOpts{EmbeddedAsSubdocument: true, DefaultKeyCase: KeyCaseLowerCamel},
+			)
+			Expect(result).To(Equal(bson.M{
+				"name":       "Test",
+				"timestamps": bson.M{"createdAt": testTime, "updatedAt": testTime},
+			}))
+		})
+
+		It("by inlining pointers to them, and omitting them if they're nil", func() {
+			type pointerStruct struct {
+				Name string `bson:"name"`
+				*Timestamps
+			}
+
+			result := ConvertStructToBSONMap(pointerStruct{Name: "Test", Timestamps: &timestamps}, nil)
+			Expect(result).To(Equal(bson.M{"name": "Test", "createdAt": testTime, "updatedAt": testTime}))
+
+			result = ConvertStructToBSONMap(pointerStruct{Name: "Test"}, nil)
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+
+		It("by nesting them under the key in their tag, if they have one", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Name       string `bson:"name"`
+					Timestamps `bson:"timestamps"`
+				}{
+					Name:       "Test",
+					Timestamps: timestamps,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{
+				"name":       "Test",
+				"timestamps": bson.M{"createdAt": testTime, "updatedAt": testTime},
+			}))
+		})
+
+		It("by honouring omitempty on their fields once inlined", func() {
+			type OptionalTimestamps struct {
+				CreatedAt time.Time  `bson:"createdAt"`
+				UpdatedAt time.Time  `bson:"updatedAt,omitempty"`
+				DeletedAt *time.Time `bson:"deletedAt,omitempty"`
+			}
+
+			result := ConvertStructToBSONMap(
+				struct {
+					Name string `bson:"name"`
+					OptionalTimestamps
+				}{
+					Name:               "Test",
+					OptionalTimestamps: OptionalTimestamps{CreatedAt: testTime},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": "Test", "createdAt": testTime}))
+		})
+
+		It("by dropping them if every one of their fields is omitted", func() {
+			type OptionalTimestamps struct {
+				UpdatedAt time.Time  `bson:"updatedAt,omitempty"`
+				DeletedAt *time.Time `bson:"deletedAt,omitempty"`
+			}
+
+			result := ConvertStructToBSONMap(
+				struct {
+					Name string `bson:"name"`
+					OptionalTimestamps
+				}{
+					Name: "Test",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+
+		It("by giving explicit fields precedence over their fields", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Timestamps
+					CreatedAt string `bson:"createdAt"`
+				}{
+					Timestamps: timestamps,
+					CreatedAt:  "Explicit",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"createdAt": "Explicit", "updatedAt": testTime}))
+		})
+
+		It("by giving parent fields precedence regardless of the order they're declared in", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					CreatedAt string `bson:"createdAt"`
+					Timestamps
+				}{
+					CreatedAt:  "Explicit",
+					Timestamps: timestamps,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"createdAt": "Explicit", "updatedAt": testTime}))
+		})
+
+		It("by recursively promoting the fields of structs embedded within them", func() {
+			type BaseModel struct {
+				ID string `bson:"_id"`
+				Timestamps
+			}
+
+			result := ConvertStructToBSONMap(
+				struct {
+					BaseModel
+					Name      string `bson:"name"`
+					UpdatedAt string `bson:"updatedAt"`
+				}{
+					BaseModel: BaseModel{ID: "1", Timestamps: timestamps},
+					Name:      "Test",
+					UpdatedAt: "Explicit",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"_id": "1", "createdAt": testTime, "updatedAt": "Explicit", "name": "Test"}))
+		})
+
+		It("by omitting nil pointers embedded within them", func() {
+			type BaseModel struct {
+				ID string `bson:"_id"`
+				*Timestamps
+			}
+			type pointerStruct struct {
+				*BaseModel
+				Name string `bson:"name"`
+			}
+
+			result := ConvertStructToBSONMap(pointerStruct{BaseModel: &BaseModel{ID: "1"}, Name: "Test"}, nil)
+			Expect(result).To(Equal(bson.M{"_id": "1", "name": "Test"}))
+
+			ordered := NewBSONMapperStruct(pointerStruct{Name: "Test"}).ToBSOND(nil)
+			Expect(ordered).To(Equal(bson.D{{Key: "name", Value: "Test"}}))
+
+			update := ConvertStructToUpdateBSON(pointerStruct{BaseModel: &BaseModel{ID: "1"}, Name: "Test"}, nil)
+			Expect(update).To(Equal(bson.M{"$set": bson.M{"_id": "1", "name": "Test"}}))
+		})
+	})
+
+	// Testing the functionality of the required tag option
+	Context("should return an error from the error API if a required field", func() {
+		type inner struct {
+			Name string `bson:"name"`
+		}
+
+		It("holds a zero scalar", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Name string `bson:"name,required"`
+				}{}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrMissingRequired)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`"name"`))
+		})
+
+		It("holds a nil pointer to a struct", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Name  string `bson:"name"`
+					Inner *inner `bson:"inner,required"`
+				}{
+					Name: "Test",
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrMissingRequired)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`"inner"`))
+		})
+
+		It("holds a nil interface", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Value interface{} `bson:"value,required"`
+				}{}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrMissingRequired)).To(BeTrue())
+		})
+
+		It("is within a nested struct", func() {
+			type requiredInner struct {
+				Name string `bson:"name,required"`
+			}
+
+			_, err := ConvertStructToBSONMapE(
+				struct {
+					Inner requiredInner `bson:"inner"`
+				}{}, nil,
+			)
+			Expect(errors.Is(err, ErrMissingRequired)).To(BeTrue())
+		})
+
+		It("but not if the required fields hold a value", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Inner *inner      `bson:"inner,required"`
+					Value interface{} `bson:"value,required"`
+				}{
+					Inner: &inner{Name: "Test"},
+					Value: 1,
+				}, nil,
+			)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"inner": bson.M{"name": "Test"}, "value": 1}))
+		})
+	})
+
+	// Testing the functionality of the SkipPointerFields option
+	Context("should skip pointer fields", func() {
+		type inner struct {
+			Value    int  `bson:"value"`
+			ValuePtr *int `bson:"valuePtr"`
+		}
+
+		testInt := 10
+		testStruct := struct {
+			Name     string  `bson:"name"`
+			NamePtr  *string `bson:"namePtr"`
+			Inner    inner   `bson:"inner"`
+			InnerPtr *inner  `bson:"innerPtr"`
+			Nil      *int    `bson:"nil"`
+		}{
+			Name:     "Test",
+			Inner:    inner{Value: 1, ValuePtr: &testInt},
+			InnerPtr: &inner{Value: 2},
+		}
+
+		It("when SkipPointerFields is set to true", func() {
+			result := ConvertStructToBSONMap(testStruct, &MappingOpts{SkipPointerFields: true})
+			Expect(result).To(Equal(bson.M{"name": "Test", "inner": bson.M{"value": 1}}))
+		})
+
+		It("unless SkipPointerFields is false", func() {
+			result := ConvertStructToBSONMap(testStruct, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{
+				"name":     "Test",
+				"inner":    bson.M{"value": 1, "valuePtr": &testInt},
+				"innerPtr": bson.M{"value": 2},
+			}))
+		})
+	})
+
+	// Testing the functionality of the hex tag option
+	Context("should convert bytes to a hex string", func() {
+		It("when a byte slice is flagged with hex", func() {
+			checksum := []byte{0xde, 0xad, 0xBE, 0xef}
+			result := ConvertStructToBSONMap(
+				struct {
+					Checksum    []byte  `bson:"checksum,hex"`
+					ChecksumPtr *[]byte `bson:"checksumPtr,hex"`
+					Raw         []byte  `bson:"raw"`
+				}{
+					Checksum:    checksum,
+					ChecksumPtr: &checksum,
+					Raw:         checksum,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"checksum": "deadbeef", "checksumPtr": "deadbeef", "raw": checksum}))
+		})
+
+		It("when a byte array is flagged with hex", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Hash [4]byte `bson:"hash,hex"`
+				}{
+					Hash: [4]byte{0x01, 0x02, 0x0a, 0xff},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"hash": "01020aff"}))
+		})
+
+		It("when an empty byte slice is flagged with hex", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Checksum []byte `bson:"checksum,hex"`
+				}{}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"checksum": ""}))
+		})
+
+		It("unless the field doesn't hold bytes", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Values []int `bson:"values,hex"`
+				}{
+					Values: []int{1, 2},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"values": []int{1, 2}}))
+		})
+	})
+
+	// Testing the functionality of the ContextFields option
+	Context("should merge in the context fields", func() {
+		type inner struct {
+			Name string `bson:"name"`
+		}
+
+		type testStruct struct {
+			Name     string `bson:"name"`
+			TenantID string `bson:"tenantId,omitempty"`
+			Inner    inner  `bson:"inner"`
+		}
+
+		It("into the top level document only", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{Name: "Test", Inner: inner{Name: "Inner"}},
+				&MappingOpts{ContextFields: map[string]interface{}{"tenantId": "tenant-1", "requestId": 10}},
+			)
+			Expect(result).To(Equal(bson.M{
+				"name":      "Test",
+				"tenantId":  "tenant-1",
+				"requestId": 10,
+				"inner":     bson.M{"name": "Inner"},
+			}))
+		})
+
+		It("taking precedence over the struct's fields", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{Name: "Test", TenantID: "tenant-2"},
+				&MappingOpts{ContextFields: map[string]interface{}{"tenantId": "tenant-1"}, GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{"name": "Test", "tenantId": "tenant-1"}))
+		})
+
+		It("even if the struct maps to an empty document", func() {
+			result := ConvertStructToBSONMap(
+				testStruct{},
+				&MappingOpts{ContextFields: map[string]interface{}{"tenantId": "tenant-1"}, GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{"tenantId": "tenant-1"}))
+		})
+
+		It("returning an error from the error API if a key is invalid", func() {
+			result, err := ConvertStructToBSONMapE(
+				testStruct{Name: "Test"},
+				&MappingOpts{ContextFields: map[string]interface{}{"$tenantId": "tenant-1"}},
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the base64 tag options
+	Context("should convert bytes to a base64 string", func() {
+		data := []byte{0xfb, 0xff, 0xbf, 0x01}
+
+		It("when a byte slice is flagged with base64", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Data    []byte  `bson:"data,base64"`
+					DataPtr *[]byte `bson:"dataPtr,base64"`
+				}{
+					Data:    data,
+					DataPtr: &data,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"data": "+/+/AQ==", "dataPtr": "+/+/AQ=="}))
+		})
+
+		It("when a byte slice is flagged with base64url", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Data []byte `bson:"data,base64url"`
+				}{
+					Data: data,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"data": "-_-_AQ=="}))
+		})
+
+		It("when a byte array is flagged with base64", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Data [3]byte `bson:"data,base64"`
+				}{
+					Data: [3]byte{'a', 'b', 'c'},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"data": "YWJj"}))
+		})
+
+		It("taking precedence in the order hex > base64 > base64url if more than one is set", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Hex    []byte `bson:"hex,base64url,base64,hex"`
+					Base64 []byte `bson:"b64,base64url,base64"`
+				}{
+					Hex:    data,
+					Base64: data,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"hex": "fbffbf01", "b64": "+/+/AQ=="}))
+		})
+	})
+
+	// Testing the functionality of the stringkey tag option
+	Context("should set the Stringer value under the stringkey", func() {
+		testTime := time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC)
+
+		It("alongside the raw value", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					DoB time.Time `bson:"dob,stringkey=dobStr"`
+				}{
+					DoB: testTime,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"dob": testTime, "dobStr": testTime.String()}))
+		})
+
+		It("instead of the raw value when combined with string", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					DoB time.Time `bson:"dob,string,stringkey=dobStr"`
+				}{
+					DoB: testTime,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"dobStr": testTime.String()}))
+		})
+
+		It("skipping nil pointers rather than panicking", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					DoB     *time.Time `bson:"dob,stringkey=dobStr"`
+					Created *time.Time `bson:"created,string"`
+				}{}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"dob": (*time.Time)(nil)}))
+		})
+
+		It("returning an error from the error API if the stringkey is invalid", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					DoB time.Time `bson:"dob,stringkey=$dob"`
+				}{
+					DoB: testTime,
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+
+		It("returning an error from the error API if the stringkey is empty", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					DoB time.Time `bson:"dob,stringkey="`
+				}{
+					DoB: testTime,
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the keyby tag option
+	Context("should key a slice of structs by their field", func() {
+		type item struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}
+
+		It("when it's flagged with keyby", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items []item `bson:"items,keyby=_id"`
+				}{
+					Items: []item{{ID: "a", Name: "First"}, {ID: "b", Name: "Second"}},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": bson.M{
+				"a": bson.M{"_id": "a", "name": "First"},
+				"b": bson.M{"_id": "b", "name": "Second"},
+			}}))
+		})
+
+		It("using the hex of an ObjectID", func() {
+			type objItem struct {
+				ID primitive.ObjectID `bson:"id"`
+			}
+
+			testID := primitive.NewObjectID()
+			result := ConvertStructToBSONMap(
+				struct {
+					Items []*objItem `bson:"items,keyby=id"`
+				}{
+					Items: []*objItem{{ID: testID}},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": bson.M{testID.Hex(): bson.M{"id": testID}}}))
+		})
+
+		It("returning an error from the error API if an element is missing the field", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Items []item `bson:"items,keyby=id"`
+				}{
+					Items: []item{{ID: "a", Name: "First"}},
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrMissingKeyField)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the unwrap tag option
+	Context("should unwrap nested structs", func() {
+		type wrapped struct {
+			Value string `bson:"value"`
+		}
+
+		type pair struct {
+			First  string `bson:"first"`
+			Second string `bson:"second,omitempty"`
+		}
+
+		It("which map to a single key", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Name    wrapped  `bson:"name,unwrap"`
+					NamePtr *wrapped `bson:"namePtr,unwrap"`
+					Raw     wrapped  `bson:"raw"`
+				}{
+					Name:    wrapped{Value: "Test"},
+					NamePtr: &wrapped{Value: "Test"},
+					Raw:     wrapped{Value: "Test"},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": "Test", "namePtr": "Test", "raw": bson.M{"value": "Test"}}))
+		})
+
+		It("once any empty fields have been omitted", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Pair pair `bson:"pair,unwrap"`
+				}{
+					Pair: pair{First: "Test"},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"pair": "Test"}))
+		})
+
+		It("but not those which map to multiple keys", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Pair pair `bson:"pair,unwrap"`
+				}{
+					Pair: pair{First: "Test", Second: "Test"},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"pair": bson.M{"first": "Test", "second": "Test"}}))
+		})
+	})
+
+	// Testing the functionality of the LowercaseInlinedMapKeys option
+	Context("should lowercase the keys of inlined maps", func() {
+		type inner struct {
+			PostCode string `bson:"PostCode"`
+		}
+
+		type testStruct struct {
+			FirstName string                 `bson:"FirstName"`
+			Inner     inner                  `bson:"inner,inline"`
+			Attrs     map[string]interface{} `bson:"attrs,inline"`
+			Raw       map[string]int         `bson:"Raw"`
+		}
+
+		testData := testStruct{
+			FirstName: "Test",
+			Inner:     inner{PostCode: "AB1 2CD"},
+			Attrs:     map[string]interface{}{"Colour": "Blue", "SHOE_SIZE": 9},
+			Raw:       map[string]int{"Key": 1},
+		}
+
+		It("when LowercaseInlinedMapKeys is set to true", func() {
+			result := ConvertStructToBSONMap(testData, &MappingOpts{LowercaseInlinedMapKeys: true})
+			Expect(result).To(Equal(bson.M{
+				"FirstName": "Test",
+				"PostCode":  "AB1 2CD",
+				"colour":    "Blue",
+				"shoe_size": 9,
+				"Raw":       map[string]int{"Key": 1},
+			}))
+		})
+
+		It("giving typed fields precedence over lowercased keys", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Attrs map[string]string `bson:"attrs,inline"`
+					Name  string            `bson:"name"`
+				}{
+					Attrs: map[string]string{"NAME": "Inline", "Other": "Value"},
+					Name:  "Explicit",
+				},
+				&MappingOpts{LowercaseInlinedMapKeys: true},
+			)
+			Expect(result).To(Equal(bson.M{"name": "Explicit", "other": "Value"}))
+		})
+
+		It("unless LowercaseInlinedMapKeys is false", func() {
+			result := ConvertStructToBSONMap(testData, nil)
+			Expect(result).To(Equal(bson.M{
+				"FirstName": "Test",
+				"PostCode":  "AB1 2CD",
+				"Colour":    "Blue",
+				"SHOE_SIZE": 9,
+				"Raw":       map[string]int{"Key": 1},
+			}))
+		})
+	})
+
+	// Testing the functionality of the tolist tag option
+	Context("should convert a map of structs into a list", func() {
+		type valueStruct struct {
+			Name string `bson:"name"`
+		}
+
+		It("when it's flagged with tolist, injecting the key into each element", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items map[string]valueStruct `bson:"items,tolist=sku"`
+				}{
+					Items: map[string]valueStruct{"b": {Name: "Second"}, "a": {Name: "First"}},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": []interface{}{
+				bson.M{"sku": "a", "name": "First"},
+				bson.M{"sku": "b", "name": "Second"},
+			}}))
+		})
+
+		It("when it's a map of pointers to structs", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items map[string]*valueStruct `bson:"items,tolist=sku"`
+				}{
+					Items: map[string]*valueStruct{"a": {Name: "First"}},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": []interface{}{bson.M{"sku": "a", "name": "First"}}}))
+		})
+
+		It("returning an error from the error API if the field isn't a map of structs", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					Items []string `bson:"items,tolist=sku"`
+				}{
+					Items: []string{"a"},
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	// Testing the functionality of the objectid tag option
+	Context("should coerce fields to an ObjectID", func() {
+		testID, _ := primitive.ObjectIDFromHex("54759eb3c090d83494e2d804")
+		testHex := testID.Hex()
+
+		It("when a hex string is flagged with objectid", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					ParentID    string  `bson:"parentId,objectid"`
+					ParentIDPtr *string `bson:"parentIdPtr,objectid"`
+					Raw         string  `bson:"raw"`
+				}{
+					ParentID:    testHex,
+					ParentIDPtr: &testHex,
+					Raw:         testHex,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"parentId": testID, "parentIdPtr": testID, "raw": testHex}))
+		})
+
+		It("when a byte slice or ObjectID is flagged with objectid", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Bytes []byte             `bson:"bytes,objectid"`
+					ID    primitive.ObjectID `bson:"id,objectid"`
+				}{
+					Bytes: testID[:],
+					ID:    testID,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"bytes": testID, "id": testID}))
+		})
+
+		It("treating empty values as null", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					ParentID    string  `bson:"parentId,objectid"`
+					ParentIDPtr *string `bson:"parentIdPtr,objectid"`
+					Omitted     string  `bson:"omitted,objectid,omitempty"`
+				}{}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"parentId": nil, "parentIdPtr": nil}))
+		})
+
+		It("returning an error from the error API if the hex is invalid", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					ParentID string `bson:"parentId,objectid"`
+				}{
+					ParentID: "not-an-object-id",
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidObjectID)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`"parentId"`))
+		})
+
+		It("returning an error from the error API if the field can't be converted", func() {
+			_, err := ConvertStructToBSONMapE(
+				struct {
+					ParentID int `bson:"parentId,objectid"`
+				}{
+					ParentID: 1,
+				}, nil,
+			)
+			Expect(errors.Is(err, ErrInvalidObjectID)).To(BeTrue())
+		})
+	})
+
+	// Testing the handling of pointers to a zero time
+	Context("should ignore pointers to a zero time", func() {
+		type testStruct struct {
+			Name      string     `bson:"name"`
+			DeletedAt *time.Time `bson:"deletedAt"`
+		}
+
+		zeroTime := time.Time{}
+		testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		It("when GenerateFilterOrPatch is set to true", func() {
+			result := ConvertStructToBSONMap(testStruct{Name: "Test", DeletedAt: &zeroTime}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+
+		It("when the field is flagged with omitempty", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					DeletedAt *time.Time `bson:"deletedAt,omitempty"`
+				}{
+					DeletedAt: &zeroTime,
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+		})
+
+		It("but not pointers to a non-zero time", func() {
+			result := ConvertStructToBSONMap(testStruct{Name: "Test", DeletedAt: &testTime}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"name": "Test", "deletedAt": &testTime}))
+		})
+
+		It("unless the field should be omitted if it is empty", func() {
+			result := ConvertStructToBSONMap(testStruct{Name: "Test", DeletedAt: &zeroTime}, nil)
+			Expect(result).To(Equal(bson.M{"name": "Test", "deletedAt": &zeroTime}))
+		})
+	})
+
+	// Testing the functionality of the len=field tag option
+	Context("should store the length of the referenced field with the len tag option", func() {
+		type testStruct struct {
+			Tags     []string       `bson:"tags"`
+			TagCount int            `bson:"tagCount,len=tags"`
+			Meta     map[string]int `bson:"meta"`
+			MetaLen  int32          `bson:"metaLen,len=Meta"`
+			Name     *string        `bson:"name"`
+			NameLen  uint8          `bson:"nameLen,len=name,omitempty"`
+		}
+
+		It("when referenced by it's key or field name", func() {
+			name := "Test"
+			result := ConvertStructToBSONMap(testStruct{
+				Tags: []string{"a", "b", "c"},
+				Meta: map[string]int{"a": 1},
+				Name: &name,
+			}, nil)
+			Expect(result).To(Equal(bson.M{
+				"tags":     []string{"a", "b", "c"},
+				"tagCount": 3,
+				"meta":     map[string]int{"a": 1},
+				"metaLen":  int32(1),
+				"name":     &name,
+				"nameLen":  uint8(4),
+			}))
+		})
+
+		It("when the referenced field is empty or a nil pointer", func() {
+			result := ConvertStructToBSONMap(testStruct{}, nil)
+			Expect(result).To(Equal(bson.M{
+				"tags":     []string(nil),
+				"tagCount": 0,
+				"meta":     map[string]int(nil),
+				"metaLen":  int32(0),
+				"name":     (*string)(nil),
+			}))
+		})
+
+		It("should return an error from the error API if the referenced field doesn't exist", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Count int `bson:"count,len=missing"`
+			}{}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidLengthField)).To(BeTrue())
+		})
+
+		It("should return an error from the error API if the referenced field has no length", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Age   int `bson:"age"`
+				Count int `bson:"count,len=age"`
+			}{}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidLengthField)).To(BeTrue())
+		})
+
+		It("should return an error from the error API if the field isn't an integer", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Tags  []string `bson:"tags"`
+				Count string   `bson:"count,len=tags"`
+			}{}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidLengthField)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the sparse tag option
+	Context("should drop nil elements from slices with the sparse tag option", func() {
+		type inner struct {
+			Name string `bson:"name"`
+		}
+
+		It("when the slice holds pointers to structs", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items []*inner `bson:"items,sparse"`
+				}{
+					Items: []*inner{nil, {Name: "first"}, nil, {Name: "second"}, nil},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": []interface{}{bson.M{"name": "first"}, bson.M{"name": "second"}}}))
+		})
+
+		It("when the slice holds pointers to values", func() {
+			first, second := "first", "second"
+			result := ConvertStructToBSONMap(
+				struct {
+					Items []*string `bson:"items,sparse"`
+				}{
+					Items: []*string{&first, nil, &second},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": []*string{&first, &second}}))
+		})
+
+		It("but not without the tag option", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Items []*inner `bson:"items"`
+				}{
+					Items: []*inner{nil, {Name: "first"}},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"items": []interface{}{(*inner)(nil), bson.M{"name": "first"}}}))
+		})
+	})
+
+	// Testing the handling of explicit BSON nulls
+	Context("should treat primitive.Null as a BSON null", func() {
+		It("when the field isn't flagged with omitempty", func() {
+			null := primitive.Null{}
+			result := ConvertStructToBSONMap(
+				struct {
+					Null    primitive.Null  `bson:"null"`
+					Value   interface{}     `bson:"value"`
+					Pointer *primitive.Null `bson:"pointer"`
+				}{
+					Value:   primitive.Null{},
+					Pointer: &null,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"null": primitive.Null{}, "value": primitive.Null{}, "pointer": primitive.Null{}}))
+		})
+
+		It("when the field is flagged with omitempty", func() {
+			null := primitive.Null{}
+			result := ConvertStructToBSONMap(
+				struct {
+					Name    string          `bson:"name"`
+					Null    primitive.Null  `bson:"null,omitempty"`
+					Value   interface{}     `bson:"value,omitempty"`
+					Pointer *primitive.Null `bson:"pointer,omitempty"`
+				}{
+					Name:    "Test",
+					Value:   primitive.Null{},
+					Pointer: &null,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+	})
+
+	// Testing the functionality of the OpaqueTypes option
+	Context("should pass the values of OpaqueTypes through without mapping them", func() {
+		type money struct {
+			Pence    int64  `bson:"pence"`
+			Currency string `bson:"currency"`
+		}
+
+		type address struct {
+			City string `bson:"city"`
+		}
+
+		type testStruct struct {
+			Price    money   `bson:"price"`
+			Discount *money  `bson:"discount"`
+			Prices   []money `bson:"prices"`
+			Address  address `bson:"address"`
+		}
+
+		price := money{Pence: 100, Currency: "GBP"}
+		opts := &MappingOpts{OpaqueTypes: []reflect.Type{reflect.TypeOf(money{})}}
+
+		It("while other nested struct types are still mapped", func() {
+			result := ConvertStructToBSONMap(testStruct{
+				Price:    price,
+				Discount: &price,
+				Prices:   []money{price},
+				Address:  address{City: "London"},
+			}, opts)
+			Expect(result).To(Equal(bson.M{
+				"price":    price,
+				"discount": &price,
+				"prices":   []interface{}{price},
+				"address":  bson.M{"city": "London"},
+			}))
+		})
+
+		It("but not if OpaqueTypes isn't set", func() {
+			result := ConvertStructToBSONMap(testStruct{Price: price, Address: address{City: "London"}}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{
+				"price":   bson.M{"pence": int64(100), "currency": "GBP"},
+				"address": bson.M{"city": "London"},
+			}))
+		})
+	})
+
+	// Testing the functionality of the KeepEmptyStrings option
+	Context("should keep empty strings in filters when KeepEmptyStrings is set", func() {
+		type testStruct struct {
+			Name     string `bson:"name"`
+			Nickname string `bson:"nickname,omitempty"`
+			Age      int    `bson:"age"`
+		}
+
+		It("when GenerateFilterOrPatch is set to true", func() {
+			result := ConvertStructToBSONMap(testStruct{}, &MappingOpts{GenerateFilterOrPatch: true, KeepEmptyStrings: true})
+			Expect(result).To(Equal(bson.M{"name": ""}))
+		})
+
+		It("but not when KeepEmptyStrings isn't set", func() {
+			result := ConvertStructToBSONMap(testStruct{Age: 1}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"age": 1}))
+		})
+	})
+
+	// Testing the functionality of the RootKey option
+	Context("should wrap the document under the RootKey", func() {
+		type testStruct struct {
+			Name string `bson:"name"`
+			Age  int    `bson:"age,omitempty"`
+		}
+
+		It("when the RootKey is set", func() {
+			result := ConvertStructToBSONMap(testStruct{Name: "Test", Age: 30}, &MappingOpts{RootKey: "data"})
+			Expect(result).To(Equal(bson.M{"data": bson.M{"name": "Test", "age": 30}}))
+		})
+
+		It("unless every field is omitted", func() {
+			result := ConvertStructToBSONMap(testStruct{}, &MappingOpts{RootKey: "data", GenerateFilterOrPatch: true})
+			Expect(result).To(BeNil())
+		})
+
+		It("should return an error from the error API if the RootKey is invalid", func() {
+			result, err := ConvertStructToBSONMapE(testStruct{Name: "Test"}, &MappingOpts{RootKey: "$data"})
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the minsize tag option
+	Context("should store integers as an int32 with the minsize tag option", func() {
+		type testStruct struct {
+			Count int64  `bson:"count,omitempty,minsize"`
+			Big   int64  `bson:"big,minsize"`
+			Size  uint64 `bson:"size, minsize"`
+			Total int64  `bson:"total"`
+		}
+
+		It("when the value fits within 32 bits", func() {
+			result := ConvertStructToBSONMap(testStruct{Count: 5, Big: math.MaxInt32 + 1, Size: 10, Total: 5}, nil)
+			Expect(result).To(Equal(bson.M{
+				"count": int32(5),
+				"big":   int64(math.MaxInt32 + 1),
+				"size":  int32(10),
+				"total": int64(5),
+			}))
+		})
+
+		It("while still honouring the other tag options", func() {
+			result := ConvertStructToBSONMap(testStruct{}, nil)
+			Expect(result).To(Equal(bson.M{"big": int32(0), "size": int32(0), "total": int64(0)}))
+		})
+	})
+
+	// Testing the handling of driver types held within an interface
+	Context("should pass driver types held within an interface through as they are", func() {
+		testID := primitive.NewObjectID()
+
+		It("when the interface holds an ObjectID", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					ID    interface{}   `bson:"_id"`
+					Refs  []interface{} `bson:"refs"`
+					IDPtr interface{}   `bson:"idPtr"`
+				}{
+					ID:    testID,
+					Refs:  []interface{}{testID, "other"},
+					IDPtr: &testID,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"_id": testID, "refs": []interface{}{testID, "other"}, "idPtr": &testID}))
+		})
+
+		It("when the interface holds a driver type with exported fields", func() {
+			ts := primitive.Timestamp{T: 1, I: 2}
+			binary := primitive.Binary{Subtype: 4, Data: []byte{1, 2}}
+			result := ConvertStructToBSONMap(
+				struct {
+					Timestamp interface{}            `bson:"timestamp"`
+					Binary    interface{}            `bson:"binary"`
+					Values    map[string]interface{} `bson:"values"`
+				}{
+					Timestamp: ts,
+					Binary:    binary,
+					Values:    map[string]interface{}{"id": testID},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"timestamp": ts, "binary": binary, "values": map[string]interface{}{"id": testID}}))
+		})
+	})
+
+	// Testing the functionality of the trim tag option
+	Context("should trim whitespace from strings with the trim tag option", func() {
+		It("when the string is padded", func() {
+			padded := "  Test  "
+			trimmed := "Test"
+			result := ConvertStructToBSONMap(
+				struct {
+					Name     string  `bson:"name,trim"`
+					Nickname *string `bson:"nickname,trim"`
+					Raw      string  `bson:"raw"`
+				}{
+					Name:     "\t Test User \n",
+					Nickname: &padded,
+					Raw:      padded,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": "Test User", "nickname": &trimmed, "raw": padded}))
+			Expect(padded).To(Equal("  Test  "))
+		})
+
+		It("before checking whether the string is empty", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Name  string `bson:"name,trim"`
+					Email string `bson:"email,trim,omitempty"`
+				}{
+					Name:  "   ",
+					Email: " \t ",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"name": ""}))
+		})
+	})
+
+	// Testing the functionality of the oneof tag option
+	Context("should validate fields with the oneof tag option", func() {
+		type testStruct struct {
+			Status   string  `bson:"status,oneof=active inactive banned"`
+			Priority int     `bson:"priority,oneof=1 2 3"`
+			Role     *string `bson:"role,oneof=admin user"`
+			Region   string  `bson:"region,omitempty,oneof=eu us"`
+		}
+
+		It("when the fields hold one of their allowed values", func() {
+			result, err := ConvertStructToBSONMapE(testStruct{Status: "banned", Priority: 2}, nil)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"status": "banned", "priority": 2, "role": (*string)(nil)}))
+		})
+
+		It("returning an error from the error API if a string field holds another value", func() {
+			result, err := ConvertStructToBSONMapE(testStruct{Status: "deleted", Priority: 1}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrNotOneOf)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring(`"status"`)))
+		})
+
+		It("returning an error from the error API if an integer field holds another value", func() {
+			role := "guest"
+			result, err := ConvertStructToBSONMapE(testStruct{Status: "active", Priority: 4, Role: &role}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrNotOneOf)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring(`"priority"`)))
+		})
+
+		It("returning an error from the error API if a pointer field points to another value", func() {
+			role := "guest"
+			result, err := ConvertStructToBSONMapE(testStruct{Status: "active", Priority: 1, Role: &role}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrNotOneOf)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the char tag option
+	Context("should convert runes to a string with the char tag option", func() {
+		It("when the field is a rune or a pointer to one", func() {
+			grade := 'é'
+			result := ConvertStructToBSONMap(
+				struct {
+					Initial rune  `bson:"initial,char"`
+					Grade   *rune `bson:"grade,char"`
+					Raw     rune  `bson:"raw"`
+				}{
+					Initial: 'J',
+					Grade:   &grade,
+					Raw:     'J',
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"initial": "J", "grade": "é", "raw": 'J'}))
+		})
+
+		It("but not if the field isn't a rune", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Initial *rune `bson:"initial,char"`
+					Count   int   `bson:"count,char"`
+				}{
+					Count: 65,
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"initial": (*rune)(nil), "count": 65}))
+		})
+	})
+
+	// Testing the conversion of rune slices with the string tag option
+	Context("should convert rune slices to a string with the string tag option", func() {
+		It("when the field is a []rune or a pointer to one", func() {
+			nickname := []rune("Jé")
+			result := ConvertStructToBSONMap(
+				struct {
+					Name     []rune  `bson:"name,string"`
+					Nickname *[]rune `bson:"nickname,string"`
+					Initials []rune  `bson:"initials,stringkey=initialsStr"`
+					Raw      []rune  `bson:"raw"`
+				}{
+					Name:     []rune("Test User"),
+					Nickname: &nickname,
+					Initials: []rune("TU"),
+					Raw:      []rune("J"),
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{
+				"name":        "Test User",
+				"nickname":    "Jé",
+				"initials":    []rune("TU"),
+				"initialsStr": "TU",
+				"raw":         []rune("J"),
+			}))
+		})
+
+		It("by omitting empty rune slices before they're converted", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Name  []rune `bson:"name,string,omitempty"`
+					Count int    `bson:"count"`
+				}{
+					Name: []rune{},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"count": 0}))
+		})
+	})
+
+	// Testing the functionality of the lower and upper tag options
+	Context("should convert the case of strings with the lower and upper tag options", func() {
+		It("when the field is a string or a pointer to one", func() {
+			code := "gb"
+			upper := "GB"
+			result := ConvertStructToBSONMap(
+				struct {
+					Email   string  `bson:"email,lower"`
+					Country *string `bson:"country,upper"`
+					Name    string  `bson:"name"`
+				}{
+					Email:   "Jane.Doe@Example.COM",
+					Country: &code,
+					Name:    "Jane Doe",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"email": "jane.doe@example.com", "country": &upper, "name": "Jane Doe"}))
+			Expect(code).To(Equal("gb"))
+		})
+
+		It("combined with trim, before checking whether the string is empty", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Email string `bson:"email,trim,lower,omitempty"`
+					Code  string `bson:"code,upper,trim,omitempty"`
+				}{
+					Email: "  Jane@Example.com ",
+					Code:  "   ",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"email": "jane@example.com"}))
+		})
+	})
+
+	// Testing the functionality of the keepempty tag option
+	Context("should keep fields tagged with keepempty when GenerateFilterOrPatch is set", func() {
+		type testStruct struct {
+			Name      string `bson:"name"`
+			IsDeleted bool   `bson:"isDeleted,keepempty"`
+			Count     int    `bson:"count,omitempty,keepempty"`
+		}
+
+		It("keeps a false bool", func() {
+			result := ConvertStructToBSONMap(testStruct{Name: "Test"}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"name": "Test", "isDeleted": false}))
+		})
+
+		It("keeps the field if GenerateFilterOrPatch isn't set", func() {
+			result := ConvertStructToBSONMap(testStruct{}, nil)
+			Expect(result).To(Equal(bson.M{"name": "", "isDeleted": false}))
+		})
+
+		It("still omits the field if it's explicitly tagged with omitempty", func() {
+			result := ConvertStructToBSONMap(testStruct{IsDeleted: true}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"isDeleted": true}))
+		})
+	})
+
+	// Testing the functionality of the numify tag option
+	Context("should convert numeric strings to native numbers with the numify tag option", func() {
+		type testStruct struct {
+			Count  string  `bson:"count,numify"`
+			Price  string  `bson:"price,numify"`
+			Weight *string `bson:"weight,numify"`
+		}
+
+		It("converts integer content to an int64", func() {
+			result := ConvertStructToBSONMap(testStruct{Count: "42", Price: "-7"}, nil)
+			Expect(result).To(Equal(bson.M{"count": int64(42), "price": int64(-7), "weight": (*string)(nil)}))
+		})
+
+		It("converts float content to a float64", func() {
+			weight := "1.5e3"
+			result := ConvertStructToBSONMap(testStruct{Count: "1", Price: "9.99", Weight: &weight}, nil)
+			Expect(result).To(Equal(bson.M{"count": int64(1), "price": 9.99, "weight": 1500.0}))
+		})
+
+		It("passes invalid content through as a string", func() {
+			result := ConvertStructToBSONMap(testStruct{Count: "many", Price: "NaN"}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"count": "many", "price": "NaN"}))
+		})
+
+		It("returns an error for invalid content when StrictNumify is set", func() {
+			result, err := ConvertStructToBSONMapE(testStruct{Count: "1", Price: "£9.99"}, &MappingOpts{StrictNumify: true})
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidNumber)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring(`"price"`)))
+		})
+	})
+
+	// Testing the functionality of the round tag option
+	Context("should round floats with the round tag option", func() {
+		type testStruct struct {
+			Price    float64  `bson:"price,round=2"`
+			Discount *float64 `bson:"discount,round=2,omitempty"`
+			Rate     float32  `bson:"rate,round=2"`
+			Count    int      `bson:"count,round=2"`
+		}
+
+		It("rounds to 2 decimal places", func() {
+			discount := 0.125
+			result := ConvertStructToBSONMap(testStruct{Price: 19.999, Discount: &discount, Rate: 1.004, Count: 3}, nil)
+			Expect(result).To(Equal(bson.M{"price": 20.0, "discount": 0.13, "rate": float32(1.0), "count": 3}))
+		})
+
+		It("rounds negative values away from zero", func() {
+			result := ConvertStructToBSONMap(testStruct{Price: -2.346}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"price": -2.35}))
+		})
+
+		It("returns an error if the number of decimal places isn't valid", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Price float64 `bson:"price,round=two"`
+			}{Price: 1.5}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidPrecision)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the UseDotNotation option
+	Context("should flatten nested structs and maps into dot separated paths when UseDotNotation is set", func() {
+		type testGeo struct {
+			Lat float64 `bson:"lat"`
+			Lng float64 `bson:"lng"`
+		}
+		type testAddress struct {
+			City string  `bson:"city,omitempty"`
+			Geo  testGeo `bson:"geo"`
+		}
+		type testStruct struct {
+			Name     string                 `bson:"name,omitempty"`
+			Address  testAddress            `bson:"address"`
+			Metadata map[string]interface{} `bson:"metadata,omitempty"`
+			Raw      testGeo                `bson:"raw,omitnested"`
+			History  []testAddress          `bson:"history,omitempty"`
+			Created  time.Time              `bson:"created,omitempty"`
+		}
+		testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		It("flattens every level of nested structs and maps", func() {
+			result := ConvertStructToBSONMap(testStruct{
+				Address:  testAddress{City: "London", Geo: testGeo{Lat: 1, Lng: 2}},
+				Metadata: map[string]interface{}{"lastActive": testTime},
+			}, &MappingOpts{UseDotNotation: true})
+			Expect(result).To(Equal(bson.M{
+				"address.city":        "London",
+				"address.geo.lat":     1.0,
+				"address.geo.lng":     2.0,
+				"metadata.lastActive": testTime,
+				"raw":                 testGeo{},
+			}))
+		})
+
+		It("composes with GenerateFilterOrPatch to produce a partial update", func() {
+			result := ConvertStructToUpdateBSON(testStruct{
+				Address: testAddress{Geo: testGeo{Lat: 1}},
+			}, &MappingOpts{UseDotNotation: true, GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"$set": bson.M{"address.geo.lat": 1.0}}))
+		})
+
+		It("doesn't flatten omitnested fields, driver types or slices of scalars", func() {
+			result := ConvertStructToBSONMap(struct {
+				Raw     testGeo   `bson:"raw,omitnested"`
+				Created time.Time `bson:"created"`
+				Tags    []string  `bson:"tags"`
+			}{
+				Raw:     testGeo{Lat: 1},
+				Created: testTime,
+				Tags:    []string{"a", "b"},
+			}, &MappingOpts{UseDotNotation: true})
+			Expect(result).To(Equal(bson.M{
+				"raw":     testGeo{Lat: 1},
+				"created": testTime,
+				"tags":    []string{"a", "b"},
+			}))
+		})
+
+		It("addresses the structs within slices by their index", func() {
+			result := ConvertStructToBSONMap(testStruct{
+				History: []testAddress{{City: "Paris", Geo: testGeo{Lng: 3}}, {City: "Rome"}},
+			}, &MappingOpts{UseDotNotation: true, GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{
+				"history.0.city":    "Paris",
+				"history.0.geo.lng": 3.0,
+				"history.1.city":    "Rome",
+			}))
+		})
+
+		It("flattens maps of structs and slices of structs within them", func() {
+			result := ConvertStructToBSONMap(struct {
+				Homes   map[string]testAddress   `bson:"homes"`
+				Visited map[string][]testAddress `bson:"visited"`
+			}{
+				Homes:   map[string]testAddress{"main": {City: "London"}},
+				Visited: map[string][]testAddress{"2020": {{City: "Paris"}}},
+			}, &MappingOpts{UseDotNotation: true, GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{
+				"homes.main.city":     "London",
+				"visited.2020.0.city": "Paris",
+			}))
+		})
+
+		It("validates the keys of each field, but not the dot separated paths it generates", func() {
+			result, err := ConvertStructToUpdateBSONE(testStruct{
+				Address: testAddress{City: "London"},
+			}, &MappingOpts{UseDotNotation: true, GenerateFilterOrPatch: true})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"$set": bson.M{"address.city": "London"}}))
+
+			result, err = ConvertStructToUpdateBSONE(struct {
+				City string `bson:"address.city"`
+			}{City: "London"}, &MappingOpts{UseDotNotation: true})
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+
+		It("doesn't flatten anything if it isn't set", func() {
+			result := ConvertStructToBSONMap(testStruct{Address: testAddress{City: "London"}}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"address": bson.M{"city": "London"}}))
+		})
+	})
+
+	// Testing the functionality of the ref tag option
+	Context("should store ids as DBRefs with the ref tag option", func() {
+		testID := primitive.NewObjectID()
+
+		It("wraps the id in a DBRef to the collection", func() {
+			result := ConvertStructToBSONMap(struct {
+				Owner primitive.ObjectID `bson:"owner,ref=users"`
+				Group string             `bson:"group,ref=groups"`
+			}{Owner: testID, Group: "admins"}, nil)
+			Expect(result).To(Equal(bson.M{
+				"owner": bson.D{{Key: "$ref", Value: "users"}, {Key: "$id", Value: testID}},
+				"group": bson.D{{Key: "$ref", Value: "groups"}, {Key: "$id", Value: "admins"}},
+			}))
+		})
+
+		It("converts the id first if it's also tagged with objectid", func() {
+			result := ConvertStructToBSONMap(struct {
+				Owner string `bson:"owner,objectid,ref=users"`
+			}{Owner: testID.Hex()}, nil)
+			Expect(result).To(Equal(bson.M{"owner": bson.D{{Key: "$ref", Value: "users"}, {Key: "$id", Value: testID}}}))
+		})
+
+		It("doesn't wrap a nil id", func() {
+			result := ConvertStructToBSONMap(struct {
+				Owner *primitive.ObjectID `bson:"owner,ref=users"`
+				Name  string              `bson:"name"`
+			}{Name: "Test"}, nil)
+			Expect(result).To(Equal(bson.M{"owner": (*primitive.ObjectID)(nil), "name": "Test"}))
+		})
+
+		It("returns an error if the collection name is empty", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Owner string `bson:"owner,ref="`
+			}{Owner: "1"}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidRef)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the ForbiddenKinds option
+	Context("should return an error if a field holds one of the ForbiddenKinds", func() {
+		type nestedStruct struct {
+			Attrs map[string]string `bson:"attrs,omitempty"`
+		}
+
+		It("errors on a map field", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Name  string            `bson:"name"`
+				Attrs map[string]string `bson:"attrs"`
+			}{Name: "Test", Attrs: map[string]string{"a": "b"}}, &MappingOpts{ForbiddenKinds: []reflect.Kind{reflect.Map}})
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrForbiddenKind)).To(BeTrue())
+			Expect(err).To(MatchError(`field "attrs" holds the forbidden kind map: forbidden kind`))
+		})
+
+		It("errors on a map held through a pointer, interface or nested struct", func() {
+			attrs := map[string]string{}
+			opts := &MappingOpts{ForbiddenKinds: []reflect.Kind{reflect.Map}}
+
+			_, err := ConvertStructToBSONMapE(struct{ Attrs *map[string]string }{Attrs: &attrs}, opts)
+			Expect(errors.Is(err, ErrForbiddenKind)).To(BeTrue())
+
+			_, err = ConvertStructToBSONMapE(struct{ Attrs *map[string]string }{}, opts)
+			Expect(errors.Is(err, ErrForbiddenKind)).To(BeTrue())
+
+			_, err = ConvertStructToBSONMapE(struct{ Attrs interface{} }{Attrs: attrs}, opts)
+			Expect(errors.Is(err, ErrForbiddenKind)).To(BeTrue())
+
+			_, err = ConvertStructToBSONMapE(struct{ Nested nestedStruct }{Nested: nestedStruct{Attrs: attrs}}, opts)
+			Expect(errors.Is(err, ErrForbiddenKind)).To(BeTrue())
+		})
+
+		It("errors on a forbidden kind held as the elements of a slice, array or map", func() {
+			opts := &MappingOpts{ForbiddenKinds: []reflect.Kind{reflect.Chan, reflect.Func}}
+
+			_, err := ConvertStructToBSONMapE(struct {
+				Chans []chan int `bson:"chans"`
+			}{}, opts)
+			Expect(err).To(MatchError(`field "chans" holds the forbidden kind chan: forbidden kind`))
+
+			_, err = ConvertStructToBSONMapE(struct {
+				Hooks map[string]func() `bson:"hooks"`
+			}{Hooks: map[string]func(){}}, opts)
+			Expect(err).To(MatchError(`field "hooks" holds the forbidden kind func: forbidden kind`))
+
+			_, err = ConvertStructToBSONMapE(struct {
+				Chans [2]*[]chan int `bson:"chans"`
+			}{}, opts)
+			Expect(errors.Is(err, ErrForbiddenKind)).To(BeTrue())
+
+			_, err = ConvertStructToBSONMapE(struct {
+				Values []interface{} `bson:"values"`
+			}{Values: []interface{}{1, map[string]interface{}{"a": make(chan int)}}}, opts)
+			Expect(errors.Is(err, ErrForbiddenKind)).To(BeTrue())
+		})
+
+		It("doesn't error on the other kinds", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Name string      `bson:"name"`
+				Any  interface{} `bson:"any"`
+				Tags []string    `bson:"tags"`
+			}{Name: "Test", Tags: []string{"a"}}, &MappingOpts{ForbiddenKinds: []reflect.Kind{reflect.Map}, GenerateFilterOrPatch: true})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"name": "Test", "tags": []string{"a"}}))
+		})
+	})
+
+	// Testing the functionality of the hash tag option
+	Context("should store the digest of strings with the hash tag option", func() {
+		type testStruct struct {
+			Email    string  `bson:"email,hash=sha256"`
+			Token    *string `bson:"token,hash=sha256,omitempty"`
+			Checksum string  `bson:"checksum,hash=md5"`
+			Count    int     `bson:"count,hash=sha256"`
+		}
+
+		It("stores the hex digest of the string", func() {
+			token := "abc"
+			result := ConvertStructToBSONMap(testStruct{Email: "test@example.com", Token: &token, Checksum: "abc", Count: 3}, nil)
+			Expect(result).To(Equal(bson.M{
+				"email":    "973dfe463ec85785f5f95af5ba3906eedb2d931c24e69824a89ea65dba4e813b",
+				"token":    "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+				"checksum": "900150983cd24fb0d6963f7d28e17f72",
+				"count":    3,
+			}))
+		})
+
+		It("omits empty strings before they're hashed", func() {
+			result := ConvertStructToBSONMap(testStruct{Email: "test@example.com"}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"email": "973dfe463ec85785f5f95af5ba3906eedb2d931c24e69824a89ea65dba4e813b"}))
+		})
+
+		It("returns an error if the algorithm isn't supported", func() {
+			result, err := ConvertStructToBSONMapE(struct {
+				Email string `bson:"email,hash=crc32"`
+			}{Email: "test@example.com"}, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrUnsupportedHash)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring(`"email"`)))
+		})
+	})
+
+	// Testing the functionality of the inline tag option
+	Context("should pull the data of nested structs and maps with the inline tag option up into the parent", func() {
+		type testInner struct {
+			City  string            `bson:"city"`
+			Attrs map[string]string `bson:",inline"`
+		}
+		type testOuter struct {
+			Street string    `bson:"street"`
+			Inner  testInner `bson:",inline"`
+		}
+		type testStruct struct {
+			Name  string    `bson:"name"`
+			Outer testOuter `bson:",inline"`
+			Count int       `bson:"count,inline"`
+		}
+
+		It("inlines structs and maps nested within inlined structs", func() {
+			result := ConvertStructToBSONMap(testStruct{
+				Name: "Test",
+				Outer: testOuter{
+					Street: "High Street",
+					Inner:  testInner{City: "London", Attrs: map[string]string{"postcode": "N1"}},
+				},
+				Count: 1,
+			}, nil)
+
+			Expect(result).To(Equal(bson.M{
+				"name":     "Test",
+				"street":   "High Street",
+				"city":     "London",
+				"postcode": "N1",
+				"count":    1,
+			}))
+		})
+
+		It("stores scalar fields under their key as normal", func() {
+			result := ConvertStructToBSONMap(struct {
+				Count *int `bson:"count,inline"`
+				Name  string
+			}{Name: "Test"}, nil)
+			Expect(result).To(Equal(bson.M{"count": (*int)(nil), "Name": "Test"}))
+		})
+
+		It("stores maps without string keys under their key, as they can't be promoted", func() {
+			result := ConvertStructToBSONMap(struct {
+				Name   string      `bson:"name"`
+				Counts map[int]int `bson:"counts,inline"`
+				Totals map[int]int `bson:"totals,flatten"`
+			}{Name: "Test", Counts: map[int]int{1: 2}, Totals: map[int]int{3: 4}}, nil)
+			Expect(result).To(Equal(bson.M{
+				"name":   "Test",
+				"counts": map[int]int{1: 2},
+				"totals": map[int]int{3: 4},
+			}))
+		})
+
+		It("omits inlined structs and maps which are empty", func() {
+			result := ConvertStructToBSONMap(testStruct{Name: "Test"}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+	})
+
+	// Testing the functionality of the DedupeSlices option
+	Context("should remove duplicate elements from slices when DedupeSlices is set", func() {
+		type testInner struct {
+			Name string `bson:"name"`
+		}
+		type testStruct struct {
+			Tags   []string      `bson:"tags"`
+			Counts [4]int        `bson:"counts"`
+			Mixed  []interface{} `bson:"mixed"`
+			Inner  []testInner   `bson:"inner"`
+			Avatar []byte        `bson:"avatar"`
+		}
+		testData := testStruct{
+			Tags:   []string{"b", "a", "b", "c", "a"},
+			Counts: [4]int{1, 2, 1, 2},
+			Mixed:  []interface{}{"a", 1, "a", int64(1), 1, nil, nil},
+			Inner:  []testInner{{Name: "a"}, {Name: "a"}},
+			Avatar: []byte{1, 1, 2},
+		}
+
+		It("removes duplicates while preserving the order of the elements", func() {
+			result := ConvertStructToBSONMap(testData, &MappingOpts{DedupeSlices: true})
+			Expect(result).To(Equal(bson.M{
+				"tags":   []string{"b", "a", "c"},
+				"counts": []int{1, 2},
+				"mixed":  []interface{}{"a", 1, int64(1), nil, nil},
+				"inner":  []interface{}{bson.M{"name": "a"}, bson.M{"name": "a"}},
+				"avatar": []byte{1, 1, 2},
+			}))
+		})
+
+		It("leaves the slices as they are by default", func() {
+			result := ConvertStructToBSONMap(testData, nil)
+			Expect(result["tags"]).To(Equal([]string{"b", "a", "b", "c", "a"}))
+		})
+	})
+
+	// Testing the functionality of the TimeLayout option
+	Context("should format times with the string tag option using the TimeLayout", func() {
+		type testStruct struct {
+			DoB      time.Time     `bson:"dob,string"`
+			Updated  *time.Time    `bson:"updated,string"`
+			Created  time.Time     `bson:"created,stringkey=createdStr"`
+			Duration time.Duration `bson:"duration,string"`
+		}
+		testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		It("formats time.Time and *time.Time fields with the layout", func() {
+			result := ConvertStructToBSONMap(testStruct{
+				DoB:      testTime,
+				Updated:  &testTime,
+				Created:  testTime,
+				Duration: time.Second,
+			}, &MappingOpts{TimeLayout: time.RFC3339})
+
+			Expect(result).To(Equal(bson.M{
+				"dob":        "2000-01-01T00:00:00Z",
+				"updated":    "2000-01-01T00:00:00Z",
+				"created":    testTime,
+				"createdStr": "2000-01-01T00:00:00Z",
+				"duration":   "1s",
+			}))
+		})
+
+		It("doesn't format nil pointers", func() {
+			result := ConvertStructToBSONMap(testStruct{DoB: testTime}, &MappingOpts{TimeLayout: time.RFC3339})
+			Expect(result).To(HaveKeyWithValue("dob", "2000-01-01T00:00:00Z"))
+			Expect(result).ToNot(HaveKey("updated"))
+		})
+
+		It("uses the String method if the TimeLayout isn't set", func() {
+			result := ConvertStructToBSONMap(testStruct{DoB: testTime}, nil)
+			Expect(result).To(HaveKeyWithValue("dob", "2000-01-01 00:00:00 +0000 UTC"))
+		})
+	})
+
+	// Testing the functionality of the FallbackTagName option
+	Context("should parse the FallbackTagName for fields without the primary tag", func() {
+		type testInner struct {
+			City string `json:"city"`
+		}
+		type testStruct struct {
+			ID       string    `bson:"_id" json:"id"`
+			Name     string    `json:"name"`
+			Nickname string    `json:"nickname,omitempty"`
+			Secret   string    `json:"-"`
+			Empty    string    `bson:"" json:"empty"`
+			Address  testInner `json:"address"`
+			Count    int
+		}
+		testData := testStruct{ID: "1", Name: "Test", Secret: "Secret", Address: testInner{City: "London"}}
+
+		It("using the fallback tag's name and options", func() {
+			result := ConvertStructToBSONMap(testData, &MappingOpts{FallbackTagName: "json"})
+			Expect(result).To(Equal(bson.M{
+				"_id":     "1",
+				"name":    "Test",
+				"empty":   "",
+				"address": bson.M{"city": "London"},
+				"Count":   0,
+			}))
+		})
+
+		It("in filters, which would otherwise use the fast path", func() {
+			result := ConvertStructToBSONMap(struct {
+				Name  string `json:"name"`
+				Count int    `json:"count"`
+			}{Name: "Test"}, &MappingOpts{FallbackTagName: "json", GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"name": "Test"}))
+		})
+
+		It("only if the option is set", func() {
+			result := ConvertStructToBSONMap(testData, nil)
+			Expect(result).To(HaveKey("Name"))
+			Expect(result).To(HaveKey("Secret"))
+		})
+	})
+
+	// Testing the inheritance of the tag configuration by nested structs
+	Context("should pass the tag configuration down to nested structs", func() {
+		type testLeaf struct {
+			Value string `json:"value"`
+		}
+		type testBranch struct {
+			Leaf   testLeaf            `json:"leaf"`
+			Leaves []testLeaf          `json:"leaves"`
+			ByKey  map[string]testLeaf `json:"byKey"`
+		}
+		type testStruct struct {
+			Branch testBranch  `json:"branch"`
+			Ptr    *testBranch `json:"ptr"`
+			Reset  testBranch  `bson:"reset,resettags"`
+		}
+		leaf := testLeaf{Value: "v"}
+		branch := testBranch{Leaf: leaf, Leaves: []testLeaf{leaf}, ByKey: map[string]testLeaf{"k": leaf}}
+
+		It("by inheriting the FallbackTagName at every level", func() {
+			result := ConvertStructToBSONMap(testStruct{Branch: branch, Ptr: &branch}, &MappingOpts{FallbackTagName: "json"})
+			expected := bson.M{
+				"leaf":   bson.M{"value": "v"},
+				"leaves": []interface{}{bson.M{"value": "v"}},
+				"byKey":  bson.M{"k": bson.M{"value": "v"}},
+			}
+			Expect(result["branch"]).To(Equal(expected))
+			Expect(result["ptr"]).To(Equal(expected))
+		})
+
+		It("by resetting to the DefaultTagName at fields with the resettags tag option", func() {
+			result := ConvertStructToBSONMap(testStruct{Reset: branch}, &MappingOpts{FallbackTagName: "json"})
+			Expect(result["reset"]).To(Equal(bson.M{
+				"Leaf":   bson.M{"Value": "v"},
+				"Leaves": []interface{}{bson.M{"Value": "v"}},
+				"ByKey":  bson.M{"k": bson.M{"Value": "v"}},
+			}))
+		})
+
+		It("by resetting a custom tag name at fields with the resettags tag option", func() {
+			type testCustom struct {
+				Leaf  testLeaf `db:"leaf"`
+				Reset struct {
+					Name string `bson:"name" db:"ignored"`
+				} `db:"reset,resettags"`
+			}
+
+			mapper := NewBSONMapperStruct(testCustom{Leaf: leaf})
+			mapper.SetTagName("db")
+			result := mapper.ToBSONMap(nil)
+			Expect(result).To(Equal(bson.M{"leaf": bson.M{"Value": "v"}, "reset": bson.M{"name": ""}}))
+		})
+	})
+
+	// Testing the functionality of the ConvertTimeToDateTime option
+	Context("should store times as a primitive.DateTime when ConvertTimeToDateTime is set", func() {
+		type testInner struct {
+			At time.Time `bson:"at"`
+		}
+		type testStruct struct {
+			Created  time.Time              `bson:"created,omitempty"`
+			Updated  *time.Time             `bson:"updated,omitempty"`
+			Inner    testInner              `bson:"inner"`
+			Times    []time.Time            `bson:"times"`
+			ByKey    map[string]time.Time   `bson:"byKey"`
+			Metadata map[string]interface{} `bson:"metadata"`
+			Values   []interface{}          `bson:"values"`
+		}
+		testTime := time.Date(2000, 1, 1, 0, 0, 0, 123456789, time.UTC)
+		dateTime := primitive.NewDateTimeFromTime(testTime)
+
+		It("including times nested within structs, slices and maps", func() {
+			result := ConvertStructToBSONMap(testStruct{
+				Created:  testTime,
+				Updated:  &testTime,
+				Inner:    testInner{At: testTime},
+				Times:    []time.Time{testTime},
+				ByKey:    map[string]time.Time{"a": testTime},
+				Metadata: map[string]interface{}{"lastActive": testTime, "nested": []interface{}{testTime, "a"}},
+				Values:   []interface{}{testTime, 1},
+			}, &MappingOpts{ConvertTimeToDateTime: true})
+
+			Expect(result).To(Equal(bson.M{
+				"created":  dateTime,
+				"updated":  dateTime,
+				"inner":    bson.M{"at": dateTime},
+				"times":    []interface{}{dateTime},
+				"byKey":    bson.M{"a": dateTime},
+				"metadata": bson.M{"lastActive": dateTime, "nested": []interface{}{dateTime, "a"}},
+				"values":   []interface{}{dateTime, 1},
+			}))
+		})
+
+		It("by omitting zero times before they're converted", func() {
+			result := ConvertStructToBSONMap(testStruct{Inner: testInner{At: testTime}}, &MappingOpts{ConvertTimeToDateTime: true, GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"inner": bson.M{"at": dateTime}}))
+		})
+
+		It("leaving the times as they are by default", func() {
+			result := ConvertStructToBSONMap(testStruct{Created: testTime, Values: []interface{}{testTime}}, nil)
+			Expect(result["created"]).To(Equal(testTime))
+			Expect(result["values"]).To(Equal([]interface{}{testTime}))
+		})
+	})
+
+	// Testing the functionality of the CoerceObjectID option
+	Context("should store hex string ids as an ObjectID when CoerceObjectID is set", func() {
+		type testInner struct {
+			ID string `bson:"_id"`
+		}
+		type testStruct struct {
+			ID    string    `bson:"_id,omitempty"`
+			Name  string    `bson:"name,omitempty"`
+			Ref   string    `bson:"ref,omitempty"`
+			Inner testInner `bson:"inner,omitempty"`
+		}
+		hex := "5f0c1a2b3c4d5e6f7a8b9c0d"
+		id, _ := primitive.ObjectIDFromHex(hex)
+
+		It("in the normal mapping path", func() {
+			result := ConvertStructToBSONMap(testStruct{ID: hex, Name: "Test", Ref: hex, Inner: testInner{ID: hex}}, &MappingOpts{CoerceObjectID: true})
+			Expect(result).To(Equal(bson.M{"_id": id, "name": "Test", "ref": hex, "inner": bson.M{"_id": id}}))
+		})
+
+		It("when UseIDifAvailable short-circuits the mapping", func() {
+			result := ConvertStructToBSONMap(testStruct{ID: hex, Name: "Test"}, &MappingOpts{CoerceObjectID: true, UseIDifAvailable: true})
+			Expect(result).To(Equal(bson.M{"_id": id}))
+		})
+
+		It("including pointers to a hex string", func() {
+			result := ConvertStructToBSONMap(struct {
+				ID *string `bson:"_id"`
+			}{ID: &hex}, &MappingOpts{CoerceObjectID: true})
+			Expect(result).To(Equal(bson.M{"_id": id}))
+		})
+
+		It("leaving strings which aren't a valid ObjectID as they are", func() {
+			for _, str := range []string{"user-1", "zzzzzzzzzzzzzzzzzzzzzzzz", hex + "00"} {
+				result, err := ConvertStructToBSONMapE(testStruct{ID: str}, &MappingOpts{CoerceObjectID: true})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(bson.M{"_id": str}))
+			}
+		})
+
+		It("only if the option is set", func() {
+			result := ConvertStructToBSONMap(testStruct{ID: hex}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"_id": hex}))
+		})
+	})
+
+	// Testing the functionality of the NullTime option
+	Context("should ignore time values equal to the NullTime", func() {
+		nullTime := time.Unix(0, 0)
+
+		It("when flagged with omitempty", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 time.Time  `bson:"testField1,omitempty"`
+					TestField2 *time.Time `bson:"testField2,omitempty"`
+					TestField3 time.Time  `bson:"testField3"`
+				}{
+					TestField1: nullTime,
+					TestField2: &nullTime,
+					TestField3: nullTime,
+				}, &MappingOpts{NullTime: nullTime},
+			)
+			Expect(result).To(Equal(bson.M{"testField3": nullTime}))
+		})
+
+		It("when GenerateFilterOrPatch is set to true", func() {
+			validTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 time.Time  `bson:"testField1"`
+					TestField2 *time.Time `bson:"testField2"`
+					TestField3 time.Time  `bson:"testField3"`
+				}{
+					TestField1: nullTime,
+					TestField2: &nullTime,
+					TestField3: validTime,
+				}, &MappingOpts{NullTime: nullTime, GenerateFilterOrPatch: true},
+			)
+			Expect(result).To(Equal(bson.M{"testField3": validTime}))
+		})
+	})
+
+	// Testing the functionality of the "string" tag
+	Context("should convert", func() {
+		It("a struct that implements Stringer interface to a string", func() {
+			// Using time.Time as it implements the Stringer interface
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 time.Time `bson:"testField1,string"`
+				}{
+					TestField1: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"testField1": "2000-01-01 00:00:00 +0000 UTC"}))
+		})
+	})
+
+	// Testing the functionality of nested structs
+	Context("should correctly parse", func() {
+		type valueStruct struct {
+			String string         `bson:"str"`
+			Slice  []string       `bson:"slice"`
+			Map    map[string]int `bson:"map"`
+			Time   time.Time      `bson:"time"`
+		}
+
+		var valuesStruct valueStruct
+		BeforeEach(func() {
+			valuesStruct = valueStruct{
+				String: "Test String",
+				Slice:  []string{"Test 1", "!@£$%^&*()", ""},
+				Map:    map[string]int{"Test 1": 100, "Test 2!": 0, "Test 3": -100},
+				Time:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+		})
+
+		It("a nested struct", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 string      `bson:"testField1"`
+					TestField2 valueStruct `bson:"nestedStruct"`
+				}{
+					TestField1: valuesStruct.String,
+					TestField2: valueStruct{
+						String: valuesStruct.String,
+						Slice:  valuesStruct.Slice,
+						Map:    valuesStruct.Map,
+						Time:   valuesStruct.Time,
+					},
+				}, nil,
+			)
+
+			expected := bson.M{
+				"str":   valuesStruct.String,
+				"slice": valuesStruct.Slice,
+				"map":   valuesStruct.Map,
+				"time":  valuesStruct.Time,
+			}
+
+			Expect(result["testField1"]).To(Equal(valuesStruct.String))
+			for k, _ := range result["nestedStruct"].(bson.M) {
+				Expect(result["nestedStruct"].(bson.M)[k]).To(Equal(expected[k]))
+			}
+		})
+
+		It("a pointer to a nested struct", func() {
+			result := ConvertStructToBSONMap(
+				&struct {
+					TestField1 string      `bson:"testField1"`
+					TestField2 valueStruct `bson:"nestedStruct"`
+				}{
+					TestField1: valuesStruct.String,
+					TestField2: valueStruct{
+						String: valuesStruct.String,
+						Slice:  valuesStruct.Slice,
+						Map:    valuesStruct.Map,
+						Time:   valuesStruct.Time,
+					},
+				}, nil,
+			)
+
+			expected := bson.M{
+				"str":   valuesStruct.String,
+				"slice": valuesStruct.Slice,
+				"map":   valuesStruct.Map,
+				"time":  valuesStruct.Time,
+			}
+
+			Expect(result["testField1"]).To(Equal(valuesStruct.String))
+			for k, _ := range result["nestedStruct"].(bson.M) {
+				Expect(result["nestedStruct"].(bson.M)[k]).To(Equal(expected[k]))
+			}
+		})
+
+		It("a nested struct with the omitnested tag", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 string      `bson:"testField1"`
+					TestField2 valueStruct `bson:"nestedStruct,omitnested"`
+				}{
+					TestField1: valuesStruct.String,
+					TestField2: valueStruct{
+						String: valuesStruct.String,
+						Slice:  valuesStruct.Slice,
+						Map:    valuesStruct.Map,
+						Time:   valuesStruct.Time,
+					},
+				}, nil,
+			)
+
+			expected := bson.M{
+				"testField1": valuesStruct.String,
+				"nestedStruct": valueStruct{
+					String: valuesStruct.String,
+					Slice:  valuesStruct.Slice,
+					Map:    valuesStruct.Map,
+					Time:   valuesStruct.Time,
+				},
+			}
+
+			Expect(result).To(Equal(expected))
+		})
+
+		It("a nested struct with the flatten tag", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 string      `bson:"testField1"`
+					TestField2 valueStruct `bson:"nestedStruct,flatten"`
+				}{
+					TestField1: valuesStruct.String,
+					TestField2: valueStruct{
+						String: valuesStruct.String,
+						Slice:  valuesStruct.Slice,
+						Map:    valuesStruct.Map,
+						Time:   valuesStruct.Time,
+					},
+				}, nil,
+			)
+
+			expected := bson.M{
+				"testField1": valuesStruct.String,
+				"str":        valuesStruct.String,
+				"slice":      valuesStruct.Slice,
+				"map":        valuesStruct.Map,
+				"time":       valuesStruct.Time,
+			}
+
+			Expect(result).To(Equal(expected))
+		})
+
+		It("a nested struct with a slice of interfaces", func() {
+			type interfaceStruct struct {
+				TestField3 []interface{} `bson:"interfaces"`
+			}
+
+			t := []int{1, 2, 3, 4}
+			interfaceSlice := make([]interface{}, len(t))
+			for i, v := range t {
+				interfaceSlice[i] = v
+			}
+
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 string          `bson:"testField1"`
+					TestField2 interfaceStruct `bson:"nestedStruct"`
+				}{
+					TestField1: valuesStruct.String,
+					TestField2: interfaceStruct{
+						TestField3: interfaceSlice,
+					},
+				}, nil,
+			)
+
+			expected := bson.M{
+				"testField1": valuesStruct.String,
+				"nestedStruct": bson.M{
+					"interfaces": interfaceSlice,
+				},
+			}
+
+			Expect(result).To(Equal(expected))
+		})
+	})
+
+	// Testing the functionality of a slice of structs
+	Context("should correctly parse", func() {
+		type valueStruct struct {
+			String string         `bson:"str"`
+			Slice  []string       `bson:"slice"`
+			Map    map[string]int `bson:"map"`
+			Time   time.Time      `bson:"time"`
+		}
+
+		var valuesStruct valueStruct
+		var expectedStruct bson.M
+
+		BeforeEach(func() {
 			valuesStruct = valueStruct{
 				String: "Test String",
 				Slice:  []string{"Test 1", "!@£$%^&*()", ""},
@@ -548,6 +3367,44 @@ var _ = Describe("The Mapping functions", func() {
 			Expect(result["sliceStruct"].([]interface{})[0]).To(Equal(expectedStruct))
 			Expect(result["sliceStruct"].([]interface{})[1]).To(Equal(expectedStruct))
 		})
+
+		It("a pointer to a slice of pointers to structs", func() {
+			slice := []*valueStruct{&valuesStruct, nil, &valuesStruct}
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 *[]*valueStruct `bson:"sliceStruct"`
+				}{
+					TestField1: &slice,
+				}, nil,
+			)
+
+			Expect(result["sliceStruct"]).To(Equal([]interface{}{expectedStruct, (*valueStruct)(nil), expectedStruct}))
+		})
+
+		It("a nil pointer to a slice of pointers to structs", func() {
+			type testStruct struct {
+				TestField1 *[]*valueStruct `bson:"sliceStruct"`
+			}
+
+			result := ConvertStructToBSONMap(testStruct{}, nil)
+			Expect(result).To(Equal(bson.M{"sliceStruct": (*[]*valueStruct)(nil)}))
+
+			result = ConvertStructToBSONMap(testStruct{}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(BeNil())
+		})
+
+		It("a pointer to an empty slice of pointers to structs", func() {
+			slice := []*valueStruct{}
+			result := ConvertStructToBSONMap(
+				struct {
+					TestField1 *[]*valueStruct `bson:"sliceStruct"`
+				}{
+					TestField1: &slice,
+				}, nil,
+			)
+
+			Expect(result).To(Equal(bson.M{"sliceStruct": []interface{}{}}))
+		})
 	})
 
 	// Testing the functionality of a map of structs
@@ -605,6 +3462,180 @@ var _ = Describe("The Mapping functions", func() {
 			Expect(result["mapStruct"].(bson.M)["Test 2"]).To(Equal(expectedStruct))
 		})
 	})
+
+	// Testing the validation of the resolved keys
+	Context("should return an error from the error API if", func() {
+		It("a key exceeds the MaxKeyLength", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 string `bson:"aVeryLongKeyName"`
+				}{
+					TestField1: "Test String",
+				}, &MappingOpts{MaxKeyLength: 10},
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+
+		It("a key is prefixed with $", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 string `bson:"$where"`
+				}{
+					TestField1: "Test String",
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+
+		It("a key contains a null byte", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 string "bson:\"test\\x00Field1\""
+				}{
+					TestField1: "Test String",
+				}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+
+		It("a key in a nested struct is invalid", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 struct {
+						TestField2 string `bson:"test.Field2"`
+					} `bson:"testField1"`
+				}{}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+	})
+
+	// Testing the functionality of the KeySanitizer option
+	Context("should handle malicious map keys", func() {
+		type valueStruct struct {
+			TestField1 string `bson:"testField1"`
+		}
+
+		It("by passing the keys through as they are by default", func() {
+			result := ConvertStructToBSONMap(
+				struct {
+					Filter bson.M            `bson:"filter"`
+					Hosts  map[string]string `bson:"hosts"`
+				}{
+					Filter: bson.M{"$gt": 5},
+					Hosts:  map[string]string{"example.com": "Test String"},
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{
+				"filter": bson.M{"$gt": 5},
+				"hosts":  map[string]string{"example.com": "Test String"},
+			}))
+		})
+
+		It("by returning an error if a map key contains a null byte, even if no options are passed", func() {
+			testStruct := struct {
+				TestField1 map[string]int `bson:"testField1"`
+			}{
+				TestField1: map[string]int{"a\x00b": 1},
+			}
+
+			result, err := ConvertStructToBSONMapE(testStruct, nil)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+			Expect(ConvertStructToBSONMap(testStruct, nil)).To(BeNil())
+		})
+
+		It("by returning an error if an inlined map key contains a null byte", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 map[string]string `bson:",inline"`
+				}{
+					TestField1: map[string]string{"a\x00b": "Test String"},
+				}, &MappingOpts{},
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+
+		It("by returning an error if a map key exceeds the MaxKeyLength", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 map[string]valueStruct `bson:"testField1"`
+				}{
+					TestField1: map[string]valueStruct{"aVeryLongKeyName": {TestField1: "Test String"}},
+				}, &MappingOpts{MaxKeyLength: 10},
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+		})
+
+		It("by using the KeySanitizer to sanitize the keys", func() {
+			sanitizer := func(key string) (string, error) {
+				return strings.NewReplacer(".", "_", "\x00", "").Replace(key), nil
+			}
+
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 map[string]int         `bson:"testField1"`
+					TestField2 map[string]valueStruct `bson:"testField2"`
+				}{
+					TestField1: map[string]int{"valid": 1, "a.b\x00": 2},
+					TestField2: map[string]valueStruct{"c.d": {TestField1: "Test String"}},
+				}, &MappingOpts{KeySanitizer: sanitizer},
+			)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{
+				"testField1": bson.M{"valid": 1, "a_b": 2},
+				"testField2": bson.M{"c_d": bson.M{"testField1": "Test String"}},
+			}))
+		})
+
+		It("by passing the map as is if the KeySanitizer doesn't change any keys", func() {
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 map[string]int `bson:"testField1"`
+				}{
+					TestField1: map[string]int{"valid": 1},
+				}, &MappingOpts{KeySanitizer: func(key string) (string, error) { return key, nil }},
+			)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(bson.M{"testField1": map[string]int{"valid": 1}}))
+		})
+
+		It("by returning any error from the KeySanitizer", func() {
+			sanitizerErr := errors.New("key rejected")
+			result, err := ConvertStructToBSONMapE(
+				struct {
+					TestField1 map[string]int `bson:"testField1"`
+				}{
+					TestField1: map[string]int{"$where": 1},
+				}, &MappingOpts{KeySanitizer: func(key string) (string, error) {
+					if strings.HasPrefix(key, "$") {
+						return "", sanitizerErr
+					}
+					return key, nil
+				}},
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, sanitizerErr)).To(BeTrue())
+		})
+	})
+
+	It("should not return an error if all keys are within the MaxKeyLength", func() {
+		result, err := ConvertStructToBSONMapE(
+			struct {
+				TestField1 string `bson:"shortKey"`
+			}{
+				TestField1: "Test String",
+			}, &MappingOpts{MaxKeyLength: 10},
+		)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(bson.M{"shortKey": "Test String"}))
+	})
 })
 
 var _ = Describe("The package should be able to map", func() {
@@ -4,6 +4,9 @@ import "strings"
 
 type tagOptions map[string]struct{}
 
+// noTagOptions is shared by every tag without any options, so it must never be written to
+var noTagOptions = tagOptions{}
+
 // Has checks whether a string is present in the tag options
 func (t tagOptions) Has(opt string) bool {
 	if _, ok := t[opt]; ok {
@@ -12,15 +15,42 @@ func (t tagOptions) Has(opt string) bool {
 	return false
 }
 
+// Value returns the value of a keyed tag option, ie. "when=beta"
+// along with whether the option is present
+func (t tagOptions) Value(opt string) (string, bool) {
+	for o := range t {
+		if len(o) > len(opt) && o[len(opt)] == '=' && strings.HasPrefix(o, opt) {
+			return o[len(opt)+1:], true
+		}
+	}
+	return "", false
+}
+
 // parseTag parses the tag on a struct field
 // it extracts both the name and the options
+//
+// Any whitespace around the options is trimmed and empty options
+// are dropped, ie. "count, omitempty,,minsize"
+//
+// If a keyed option is repeated, the first value wins and the rest
+// are dropped, ie. "address,group=billing,group=shipping" is grouped under "billing"
 func parseTag(tag string) (string, tagOptions) {
+	// Most tags don't hold any options, so there is nothing to split
+	if !strings.Contains(tag, ",") {
+		return tag, noTagOptions
+	}
+
 	res := strings.Split(tag, ",")
 	m := make(tagOptions)
 	for i, opt := range res {
-		if i == 0 {
+		if opt = strings.TrimSpace(opt); i == 0 || opt == "" {
 			continue
 		}
+		if eq := strings.IndexByte(opt, '='); eq > 0 {
+			if _, ok := m.Value(opt[:eq]); ok {
+				continue
+			}
+		}
 		m[opt] = struct{}{}
 	}
 	return res[0], m
@@ -37,6 +37,38 @@ var _ = Describe("Tags should", func() {
 		})
 	})
 
+	Context("use \"Value()\" to get the value of a keyed tag", func() {
+		var tagOpts tagOptions
+
+		BeforeEach(func() {
+			_, tagOpts = parseTag("test1,omitempty,when=beta,empty=")
+		})
+
+		It("if the tag exists", func() {
+			result, ok := tagOpts.Value("when")
+			Expect(result).To(Equal("beta"))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("if the tag exists with an empty value", func() {
+			result, ok := tagOpts.Value("empty")
+			Expect(result).To(Equal(""))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("if the tag doesn't exist", func() {
+			result, ok := tagOpts.Value("FAIL")
+			Expect(result).To(Equal(""))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("if the tag exists without a value", func() {
+			result, ok := tagOpts.Value("omitempty")
+			Expect(result).To(Equal(""))
+			Expect(ok).To(BeFalse())
+		})
+	})
+
 	Context("parse strings into Tags", func() {
 
 		It("if a tag follows the expected format", func() {
@@ -62,5 +94,27 @@ var _ = Describe("Tags should", func() {
 			Expect(tagName).To(Equal("test1"))
 			Expect(tagOpts).To(Equal(tagOptions{"opt1": struct{}{}, "opt2": struct{}{}}))
 		})
+
+		It("if a tag has mixed keyed and valueless options", func() {
+			tagName, tagOpts := parseTag("count,omitempty,minsize,when=beta,truncate")
+			Expect(tagName).To(Equal("count"))
+			Expect(tagOpts).To(Equal(tagOptions{"omitempty": struct{}{}, "minsize": struct{}{}, "when=beta": struct{}{}, "truncate": struct{}{}}))
+		})
+
+		It("if a tag has whitespace around or empty options", func() {
+			tagName, tagOpts := parseTag("count, omitempty,,minsize ")
+			Expect(tagName).To(Equal("count"))
+			Expect(tagOpts).To(Equal(tagOptions{"omitempty": struct{}{}, "minsize": struct{}{}}))
+		})
+
+		It("if a keyed option is repeated, keeping the first value", func() {
+			tagName, tagOpts := parseTag("address,group=billing,omitempty,group=shipping")
+			Expect(tagName).To(Equal("address"))
+			Expect(tagOpts).To(Equal(tagOptions{"group=billing": struct{}{}, "omitempty": struct{}{}}))
+
+			group, ok := tagOpts.Value("group")
+			Expect(ok).To(BeTrue())
+			Expect(group).To(Equal("billing"))
+		})
 	})
 })
@@ -0,0 +1,107 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"reflect"
+	"time"
+)
+
+// InferBSONTypes maps the struct (factoring in any options passed) and returns the BSON type
+// each value would be stored as, rather than the value itself. The types are named using the
+// aliases accepted by "bsonType" within a "$jsonSchema" validator, ie.
+//
+// 	 // bson.M{ "firstName": "string", "age": "int", "address": "object", "tags": "array" }
+//
+// Nested documents and arrays are reported as "object" and "array", nil values as "null".
+// As with the Mongo-Go Driver, an int is reported as "int" if it fits within 32 bits.
+//
+// Returns nil if the struct maps to nil
+func InferBSONTypes(s interface{}, opts *MappingOpts) bson.M {
+	out, _ := InferBSONTypesE(s, opts)
+	return out
+}
+
+// InferBSONTypesE behaves the same as InferBSONTypes, however it returns
+// an error if the struct can't be mapped
+func InferBSONTypesE(s interface{}, opts *MappingOpts) (bson.M, error) {
+	doc, err := ConvertStructToBSONMapE(s, opts)
+	if err != nil || len(doc) == 0 {
+		return nil, err
+	}
+
+	out := make(bson.M, len(doc))
+	for k, v := range doc {
+		out[k] = bsonType(v)
+	}
+	return out, nil
+}
+
+// bsonType returns the "$jsonSchema" alias of the BSON type the value would be stored as
+func bsonType(val interface{}) string {
+	switch val.(type) {
+	case nil, primitive.Null:
+		return "null"
+	case time.Time, primitive.DateTime:
+		return "date"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.Decimal128:
+		return "decimal"
+	case primitive.Binary:
+		return "binData"
+	case primitive.Timestamp:
+		return "timestamp"
+	case primitive.Regex:
+		return "regex"
+	case primitive.JavaScript:
+		return "javascript"
+	case primitive.CodeWithScope:
+		return "javascriptWithScope"
+	case primitive.Symbol:
+		return "symbol"
+	case primitive.DBPointer:
+		return "dbPointer"
+	case primitive.Undefined:
+		return "undefined"
+	case primitive.MinKey:
+		return "minKey"
+	case primitive.MaxKey:
+		return "maxKey"
+	case bson.D, bson.M:
+		return "object"
+	}
+
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "null"
+		}
+		return bsonType(v.Elem().Interface())
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "int"
+	case reflect.Int:
+		if v.Int() < math.MinInt32 || v.Int() > math.MaxInt32 {
+			return "long"
+		}
+		return "int"
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "long"
+	case reflect.Float32, reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "string"
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return "binData"
+		}
+		return "array"
+	case reflect.Array:
+		return "array"
+	}
+	return "object"
+}
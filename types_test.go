@@ -0,0 +1,84 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"time"
+)
+
+var _ = Describe("InferBSONTypes", func() {
+	type address struct {
+		City string `bson:"city"`
+	}
+
+	type user struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		FirstName string             `bson:"firstName"`
+		Age       int32              `bson:"age"`
+		Count     int                `bson:"count"`
+		Big       int                `bson:"big"`
+		Views     int64              `bson:"views"`
+		Score     float64            `bson:"score"`
+		Active    bool               `bson:"active"`
+		CreatedAt time.Time          `bson:"createdAt"`
+		Nickname  *string            `bson:"nickname"`
+		Address   address            `bson:"address"`
+		Tags      []string           `bson:"tags"`
+		Avatar    []byte             `bson:"avatar"`
+		Meta      map[string]int     `bson:"meta"`
+		UpdatedAt time.Time          `bson:"updatedAt,timestamp"`
+	}
+
+	It("should return the BSON type of each field", func() {
+		nickname := "Test"
+		result := InferBSONTypes(
+			user{
+				FirstName: "Test User",
+				Big:       math.MaxInt32 + 1,
+				Nickname:  &nickname,
+			}, nil,
+		)
+
+		Expect(result).To(Equal(bson.M{
+			"_id":       "objectId",
+			"firstName": "string",
+			"age":       "int",
+			"count":     "int",
+			"big":       "long",
+			"views":     "long",
+			"score":     "double",
+			"active":    "bool",
+			"createdAt": "date",
+			"nickname":  "string",
+			"address":   "object",
+			"tags":      "array",
+			"avatar":    "binData",
+			"meta":      "object",
+			"updatedAt": "timestamp",
+		}))
+	})
+
+	It("should report nil values as null", func() {
+		result := InferBSONTypes(
+			struct {
+				Nickname *string     `bson:"nickname"`
+				Value    interface{} `bson:"value"`
+			}{}, nil,
+		)
+		Expect(result).To(Equal(bson.M{"nickname": "null", "value": "null"}))
+	})
+
+	It("should factor in the mapping options", func() {
+		result := InferBSONTypes(user{FirstName: "Test User"}, &MappingOpts{RemoveID: true, GenerateFilterOrPatch: true})
+		Expect(result).To(Equal(bson.M{"firstName": "string"}))
+	})
+
+	It("should return an error from the error API if it isn't passed a struct", func() {
+		result, err := InferBSONTypesE("Test String", nil)
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+})
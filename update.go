@@ -0,0 +1,401 @@
+package mapper
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// updateOperators holds the tag options which route a field out
+// of the "$set", along with the update operator they route it into
+var updateOperators = []struct {
+	opt      string
+	operator string
+}{
+	{"unset", "$unset"},
+	{"push", "$push"},
+	{"pull", "$pull"},
+	{"inc", "$inc"},
+	{"max", "$max"},
+	{"min", "$min"},
+	{"currentdate", "$currentDate"},
+}
+
+// The bitwise operations which can be used with the "bit=operation" tag option
+var bitOperations = map[string]bool{
+	"and": true,
+	"or":  true,
+	"xor": true,
+}
+
+// updateOperator is the update operator a field is routed into, along with
+// any argument the operator takes (ie. the operation for "$bit")
+type updateOperator struct {
+	name string
+	arg  string
+}
+
+// ConvertStructToUpdateBSON maps the struct and wraps the result in a "$set" update document
+// ready to be used in an update operation, ie. bson.M { "$set": bson.M { ... } }
+//
+// Fields with an update operator tag option are routed into that operator instead (see ToUpdateOperators).
+// If AutoUpdatedAtKey is set, the current time is also set under that key.
+//
+// Returns nil if there is nothing to update, including when UseIDifAvailable short-circuits the
+// mapping (as the "_id" can't be updated)
+func ConvertStructToUpdateBSON(s interface{}, opts *MappingOpts) bson.M {
+	out, _ := ConvertStructToUpdateBSONE(s, opts)
+	return out
+}
+
+// ConvertStructToUpdateBSONE behaves the same as ConvertStructToUpdateBSON, however
+// it returns an error if the struct can't be mapped
+func ConvertStructToUpdateBSONE(s interface{}, opts *MappingOpts) (bson.M, error) {
+	if err := checkStruct(s); err != nil {
+		return nil, err
+	}
+	return NewBSONMapperStruct(s).ToUpdateOperatorsE(opts)
+}
+
+// ToUpdateOperators maps the struct into an update document, assembling the section for each
+// update operator in one pass. Fields are routed into "$set" unless they have one of the
+// following tag options:
+//
+// 	 // "unset" - Routes the field into "$unset", as long as it holds a non-zero value (ie. a bool flag set to true)
+// 	 // "push" - Routes the field into "$push"
+// 	 // "pull" - Routes the field into "$pull"
+// 	 // "inc" - Routes a numeric field into "$inc" as the delta to increment by, as long as it is non-zero
+// 	 // "max" - Routes the field into "$max", so it's only updated if the value is greater than the stored value
+// 	 // "min" - Routes the field into "$min", so it's only updated if the value is less than the stored value
+// 	 // "bit=operation" - Routes an integer field into "$bit" as { operation: value }, where the operation is "and", "or" or "xor"
+// 	 // "currentdate" - Routes the field into "$currentDate" as { key: true } regardless of it's value, so the server sets the current date
+// 	 // "version" - Routes the field into "$inc" as { key: 1 } regardless of it's value, for optimistic concurrency (see ToVersionedUpdate)
+//
+// A field can only be routed into one update operator, so an error is returned if it has more than one of them.
+//
+// The "arrayfilter" tag option targets the elements of an array field rather than the field itself,
+// so each key within the field's nested document is prefixed with a positional operator:
+//
+// 	 // "arrayfilter" - Targets the first matched element, ie. "items.$.price"
+// 	 // "arrayfilter=identifier" - Targets the elements matched by the identifier within the operation's arrayFilters, ie. "items.$[elem].price"
+//
+// The same options and tag options as ToBSONMap are factored into the mapping of each field,
+// and if AutoUpdatedAtKey is set the current time is set under that key within the "$set".
+//
+// Returns nil if there is nothing to update
+func (s *StructToBSON) ToUpdateOperators(opts *MappingOpts) bson.M {
+	out, _ := s.ToUpdateOperatorsE(opts)
+	return out
+}
+
+// ToUpdateOperatorsE behaves the same as ToUpdateOperators, however it returns
+// an error if the struct can't be mapped
+func (s *StructToBSON) ToUpdateOperatorsE(opts *MappingOpts) (bson.M, error) {
+	// The "_id" of a document can't be updated, so a new one is never generated
+	o := MappingOpts{}
+	if opts = withDefaults(opts); opts != nil {
+		o = *opts
+	}
+	o.GenerateIDIfMissing = false
+	o.validateMapKeys = true
+	opts = &o
+
+	doc, err := s.topLevelDoc(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// If UseIDifAvailable short-circuits the mapping only the "_id" is mapped,
+	// which can't be updated, so there is nothing to set
+	if opts != nil && opts.UseIDifAvailable && hasID(doc) {
+		return nil, nil
+	}
+
+	operators, err := s.operatorKeys(opts)
+	if err != nil {
+		return nil, err
+	}
+	versionKey, versioned := s.versionKey(opts)
+	positions, err := s.positionalKeys(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The current date is set on the field itself, so it's keys are collected before
+	// the keys of any nested documents are routed into the field's operator
+	var currentDates []string
+	for key, operator := range operators {
+		if operator.name == "$currentDate" {
+			currentDates = append(currentDates, key)
+		}
+	}
+	doc = expandPositional(doc, positions, operators)
+
+	out := bson.M{}
+	for _, e := range doc {
+		// The version is incremented rather than set to the current version
+		if versioned && e.Key == versionKey {
+			continue
+		}
+
+		operator, ok := operators[e.Key]
+		if !ok && opts != nil && opts.UseDotNotation {
+			// The keys of flattened fields are routed into the operator of the field they're held within
+			operator, ok = operators[strings.SplitN(e.Key, ".", 2)[0]]
+		}
+		if !ok {
+			operator.name = "$set"
+		}
+
+		// Unsetting a zero flag or incrementing by a zero delta would have no effect,
+		// including where the flag or delta is held by a pointer
+		val := e.Value
+		v := reflect.Indirect(reflect.ValueOf(val))
+		if (operator.name == "$unset" || operator.name == "$inc") && (!v.IsValid() || v.IsZero()) {
+			continue
+		}
+
+		switch operator.name {
+		case "$currentDate":
+			continue
+		case "$unset":
+			val = ""
+		case "$inc":
+			val = v.Interface()
+		case "$bit":
+			if !bitOperations[operator.arg] {
+				return nil, fmt.Errorf("field %q has the bit operation %q: %w", e.Key, operator.arg, ErrInvalidUpdateOperator)
+			}
+			if !isInteger(val) {
+				return nil, fmt.Errorf("field %q of type %T can't be used with \"$bit\": %w", e.Key, val, ErrInvalidUpdateOperator)
+			}
+			val = bson.M{operator.arg: val}
+		}
+		setOperator(out, operator.name, e.Key, val)
+	}
+
+	// The server sets the current date, so these fields are set even if they'd otherwise be omitted
+	for _, key := range currentDates {
+		if position, ok := positions[key]; ok {
+			key = positionalPath(key, position)
+		}
+		setOperator(out, "$currentDate", key, true)
+	}
+
+	if opts != nil && opts.AutoUpdatedAtKey != "" {
+		if err := validateKey(opts.AutoUpdatedAtKey, opts); err != nil {
+			return nil, err
+		}
+		setOperator(out, "$set", opts.AutoUpdatedAtKey, opts.now())
+	}
+
+	// Every update increments the version, even if it would otherwise be omitted
+	if versioned {
+		setOperator(out, "$inc", versionKey, 1)
+	}
+
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// ConvertStructToUpdatePipeline maps the struct into an update pipeline (MongoDB 5.0+) which sets
+// each of the struct's fields. Unlike ConvertStructToUpdateBSON, keys which contain a "." or are
+// prefixed with "$" (ie. from a map with the "inline" tag option) are allowed, as they're set using
+// "$setField" rather than being treated as a path or an operator:
+//
+// 	 // 1. { "$set": { key: { "$literal": value }, ... } } - For all other keys
+// 	 // 2. { "$replaceWith": { "$setField": { "field": { "$literal": key }, "input": "$$ROOT", "value": { "$literal": value } } } }
+//
+// Every value is wrapped in "$literal" so that it can't be interpreted as an aggregation expression.
+// Update operator tag options aren't factored in, as every field is set.
+//
+// Returns nil if there is nothing to set
+func ConvertStructToUpdatePipeline(s interface{}, opts *MappingOpts) []bson.D {
+	out, _ := ConvertStructToUpdatePipelineE(s, opts)
+	return out
+}
+
+// ConvertStructToUpdatePipelineE behaves the same as ConvertStructToUpdatePipeline,
+// however it returns an error if the struct can't be mapped
+func ConvertStructToUpdatePipelineE(s interface{}, opts *MappingOpts) ([]bson.D, error) {
+	if err := checkStruct(s); err != nil {
+		return nil, err
+	}
+
+	o := MappingOpts{}
+	if opts = withDefaults(opts); opts != nil {
+		o = *opts
+	}
+	o.allowSetFieldKeys = true
+	o.GenerateIDIfMissing = false
+
+	doc, err := NewBSONMapperStruct(s).topLevelDoc(&o)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.AutoUpdatedAtKey != "" {
+		if err := validateKey(o.AutoUpdatedAtKey, &o); err != nil {
+			return nil, err
+		}
+		doc = setElem(doc, o.AutoUpdatedAtKey, o.now())
+	}
+
+	var set bson.D
+	var setFields []bson.D
+	for _, e := range doc {
+		val := bson.D{{Key: "$literal", Value: e.Value}}
+		if !strings.HasPrefix(e.Key, "$") && !strings.Contains(e.Key, ".") {
+			set = append(set, bson.E{Key: e.Key, Value: val})
+			continue
+		}
+
+		setFields = append(setFields, bson.D{{Key: "$replaceWith", Value: bson.D{{Key: "$setField", Value: bson.D{
+			{Key: "field", Value: bson.D{{Key: "$literal", Value: e.Key}}},
+			{Key: "input", Value: "$$ROOT"},
+			{Key: "value", Value: val},
+		}}}}})
+	}
+
+	var pipeline []bson.D
+	if len(set) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$set", Value: set}})
+	}
+	return append(pipeline, setFields...), nil
+}
+
+// operatorKeys returns the resolved keys of any fields with an
+// update operator tag option, mapped to the operator they're routed into.
+// A field can only be routed into one operator, so returns an error if it has more than one
+func (s *StructToBSON) operatorKeys(opts *MappingOpts) (map[string]updateOperator, error) {
+	keys := make(map[string]updateOperator)
+	var err error
+	s.eachFieldKey(opts, func(key string, tagOpts tagOptions) {
+		var found []updateOperator
+		for _, e := range updateOperators {
+			if tagOpts.Has(e.opt) {
+				found = append(found, updateOperator{name: e.operator})
+			}
+		}
+		if op, ok := tagOpts.Value("bit"); ok {
+			found = append(found, updateOperator{name: "$bit", arg: op})
+		}
+		if len(found) == 0 {
+			return
+		}
+		if len(found) > 1 && err == nil {
+			err = fmt.Errorf("field %q can't be routed into both %q and %q: %w", key, found[0].name, found[1].name, ErrInvalidUpdateOperator)
+		}
+		keys[key] = found[0]
+	})
+	return keys, err
+}
+
+// positionalKeys returns the resolved keys of any fields with the "arrayfilter"
+// tag option, mapped to the identifier of the array filter ("" for the first match)
+func (s *StructToBSON) positionalKeys(opts *MappingOpts) (map[string]string, error) {
+	keys := make(map[string]string)
+	var err error
+	s.eachFieldKey(opts, func(key string, tagOpts tagOptions) {
+		identifier, ok := tagOpts.Value("arrayfilter")
+		if !ok {
+			if !tagOpts.Has("arrayfilter") {
+				return
+			}
+		} else if !isFilterIdentifier(identifier) && err == nil {
+			err = fmt.Errorf("field %q has the array filter identifier %q: %w", key, identifier, ErrInvalidUpdateOperator)
+		}
+		keys[key] = identifier
+	})
+	return keys, err
+}
+
+// isFilterIdentifier checks whether the identifier can be used within arrayFilters,
+// it must begin with a lowercase letter and only contain alphanumeric characters
+func isFilterIdentifier(identifier string) bool {
+	for i, r := range identifier {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case (r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') && i > 0:
+		default:
+			return false
+		}
+	}
+	return identifier != ""
+}
+
+// positionalPath returns the path to the array elements targeted by the identifier
+func positionalPath(key string, identifier string) string {
+	if identifier == "" {
+		return key + ".$"
+	}
+	return key + ".$[" + identifier + "]"
+}
+
+// expandPositional replaces the fields with the "arrayfilter" tag option with the positional
+// paths to each key within their nested document, routing each path into the field's operator
+func expandPositional(doc bson.D, positions map[string]string, operators map[string]updateOperator) bson.D {
+	if len(positions) == 0 {
+		return doc
+	}
+
+	out := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		identifier, ok := positions[e.Key]
+		if !ok {
+			out = append(out, e)
+			continue
+		}
+
+		path := positionalPath(e.Key, identifier)
+		elems := promotedElems(e.Value)
+		if elems == nil {
+			// The value replaces the whole array element
+			elems = bson.D{{Value: e.Value}}
+		}
+
+		for _, elem := range elems {
+			key := path
+			if elem.Key != "" {
+				key += "." + elem.Key
+			}
+			if operator, ok := operators[e.Key]; ok {
+				operators[key] = operator
+			}
+			out = append(out, bson.E{Key: key, Value: elem.Value})
+		}
+	}
+	return out
+}
+
+// isInteger checks whether the value is an integer, or a pointer to one
+func isInteger(val interface{}) bool {
+	switch reflect.Indirect(reflect.ValueOf(val)).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// setOperator sets the value of the key within the operator's section of the update document
+func setOperator(update bson.M, operator string, key string, val interface{}) {
+	section, ok := update[operator].(bson.M)
+	if !ok {
+		section = bson.M{}
+		update[operator] = section
+	}
+	section[key] = val
+}
+
+// now returns the current time in UTC, using the NowFunc if one has been set
+func (opts *MappingOpts) now() time.Time {
+	if opts.NowFunc != nil {
+		return opts.NowFunc()
+	}
+	return time.Now().UTC()
+}
@@ -0,0 +1,436 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+var _ = Describe("ConvertStructToUpdateBSON", func() {
+	type testStruct struct {
+		TestField1 string `bson:"testField1"`
+		TestField2 int    `bson:"testField2"`
+	}
+
+	fixedTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixedClock := func() time.Time { return fixedTime }
+
+	It("should wrap the mapped struct in a $set", func() {
+		result := ConvertStructToUpdateBSON(testStruct{TestField1: "Test String"}, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(result).To(Equal(bson.M{"$set": bson.M{"testField1": "Test String"}}))
+	})
+
+	It("should return nil if there is nothing to set", func() {
+		result := ConvertStructToUpdateBSON(testStruct{}, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(result).To(BeNil())
+	})
+
+	It("should return an error if a struct isn't passed", func() {
+		result, err := ConvertStructToUpdateBSONE("Test String", nil)
+		Expect(result).To(BeNil())
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should return nil if UseIDifAvailable short-circuits the mapping", func() {
+		type idStruct struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}
+		opts := &MappingOpts{UseIDifAvailable: true, GenerateFilterOrPatch: true, AutoUpdatedAtKey: "updatedAt", NowFunc: fixedClock}
+
+		result, err := ConvertStructToUpdateBSONE(idStruct{ID: "1", Name: "Test String"}, opts)
+		Expect(err).To(BeNil())
+		Expect(result).To(BeNil())
+
+		result = ConvertStructToUpdateBSON(idStruct{Name: "Test String"}, opts)
+		Expect(result).To(Equal(bson.M{"$set": bson.M{"name": "Test String", "updatedAt": fixedTime}}))
+	})
+
+	It("should never generate an _id", func() {
+		result := ConvertStructToUpdateBSON(testStruct{TestField1: "Test String"}, &MappingOpts{GenerateFilterOrPatch: true, GenerateIDIfMissing: true})
+		Expect(result).To(Equal(bson.M{"$set": bson.M{"testField1": "Test String"}}))
+	})
+
+	Context("with update operator tag options", func() {
+		type updateStruct struct {
+			Name     string   `bson:"name"`
+			Nickname bool     `bson:"nickname,unset"`
+			Email    bool     `bson:"email,unset"`
+			Tags     string   `bson:"tags,push"`
+			Blocked  []string `bson:"blocked,pull"`
+			Views    int      `bson:"views,inc"`
+		}
+
+		It("should assemble every operator in one pass", func() {
+			result := NewBSONMapperStruct(updateStruct{
+				Name:     "Test String",
+				Nickname: true,
+				Tags:     "new",
+				Blocked:  []string{"spam"},
+				Views:    2,
+			}).ToUpdateOperators(&MappingOpts{AutoUpdatedAtKey: "updatedAt", NowFunc: fixedClock})
+
+			Expect(result).To(Equal(bson.M{
+				"$set":   bson.M{"name": "Test String", "updatedAt": fixedTime},
+				"$unset": bson.M{"nickname": ""},
+				"$push":  bson.M{"tags": "new"},
+				"$pull":  bson.M{"blocked": []string{"spam"}},
+				"$inc":   bson.M{"views": 2},
+			}))
+		})
+
+		It("should only include the operators which are used", func() {
+			result := ConvertStructToUpdateBSON(updateStruct{Views: 1}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"$inc": bson.M{"views": 1}}))
+		})
+
+		It("should increment by a non-zero delta", func() {
+			result := ConvertStructToUpdateBSON(updateStruct{Views: -3}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"$inc": bson.M{"views": -3}}))
+		})
+
+		It("should omit a zero delta, even if it isn't flagged with omitempty", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Name  string `bson:"name"`
+					Views int    `bson:"views,inc"`
+				}{
+					Name: "Test String",
+				}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$set": bson.M{"name": "Test String"}}))
+		})
+
+		It("should increment by the value of a pointer, omitting it if it points to a zero delta", func() {
+			type pointerStruct struct {
+				Views *int `bson:"views,inc"`
+			}
+			zero, delta := 0, 2
+
+			Expect(ConvertStructToUpdateBSON(pointerStruct{Views: &delta}, nil)).To(Equal(bson.M{"$inc": bson.M{"views": 2}}))
+			Expect(ConvertStructToUpdateBSON(pointerStruct{Views: &zero}, nil)).To(BeNil())
+			Expect(ConvertStructToUpdateBSON(pointerStruct{}, nil)).To(BeNil())
+		})
+
+		It("should route renamed keys into their operator", func() {
+			result := ConvertStructToUpdateBSON(updateStruct{Views: 1}, &MappingOpts{
+				GenerateFilterOrPatch: true,
+				RenameKeys:            map[string]string{"views": "pageViews"},
+			})
+			Expect(result).To(Equal(bson.M{"$inc": bson.M{"pageViews": 1}}))
+		})
+
+		It("should return nil if there is nothing to update", func() {
+			result := NewBSONMapperStruct(updateStruct{}).ToUpdateOperators(&MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(BeNil())
+		})
+	})
+
+	Context("with the max and min tag options", func() {
+		It("should route fields tagged with max into $max", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					HighScore int `bson:"highScore,max"`
+				}{HighScore: 100}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$max": bson.M{"highScore": 100}}))
+		})
+
+		It("should route fields tagged with min into $min", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					LowScore int `bson:"lowScore,min"`
+				}{LowScore: 10}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$min": bson.M{"lowScore": 10}}))
+		})
+
+		It("should assemble both operators alongside the $set", func() {
+			testTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Name      string    `bson:"name"`
+					LastSeen  time.Time `bson:"lastSeen,max"`
+					FirstSeen time.Time `bson:"firstSeen,min"`
+				}{Name: "Test", LastSeen: testTime, FirstSeen: testTime}, nil,
+			)
+			Expect(result).To(Equal(bson.M{
+				"$set": bson.M{"name": "Test"},
+				"$max": bson.M{"lastSeen": testTime},
+				"$min": bson.M{"firstSeen": testTime},
+			}))
+		})
+	})
+
+	Context("with the bit tag option", func() {
+		It("should generate an and clause", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Flags int `bson:"flags,bit=and"`
+				}{Flags: 5}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$bit": bson.M{"flags": bson.M{"and": 5}}}))
+		})
+
+		It("should generate an or clause", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Flags uint8 `bson:"flags,bit=or"`
+				}{Flags: 2}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$bit": bson.M{"flags": bson.M{"or": uint8(2)}}}))
+		})
+
+		It("should generate an xor clause", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Flags int64 `bson:"flags,bit=xor"`
+				}{Flags: 1}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$bit": bson.M{"flags": bson.M{"xor": int64(1)}}}))
+		})
+
+		It("should return an error if the bit operation is invalid", func() {
+			result, err := ConvertStructToUpdateBSONE(
+				struct {
+					Flags int `bson:"flags,bit=nand"`
+				}{Flags: 1}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidUpdateOperator)).To(BeTrue())
+		})
+
+		It("should return an error if the field isn't an integer", func() {
+			result, err := ConvertStructToUpdateBSONE(
+				struct {
+					Flags float64 `bson:"flags,bit=and"`
+				}{Flags: 1}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidUpdateOperator)).To(BeTrue())
+		})
+	})
+
+	It("should return the same error each time if a field has more than one update operator tag option", func() {
+		for i := 0; i < 50; i++ {
+			result, err := ConvertStructToUpdateBSONE(
+				struct {
+					Score int `bson:"score,inc,max"`
+				}{Score: 5}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidUpdateOperator)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring(`can't be routed into both "$inc" and "$max"`)))
+		}
+	})
+
+	Context("with the currentdate tag option", func() {
+		type currentDateStruct struct {
+			Name       string    `bson:"name"`
+			ModifiedAt time.Time `bson:"modifiedAt,currentdate"`
+		}
+
+		It("should route the field into $currentDate", func() {
+			result := ConvertStructToUpdateBSON(currentDateStruct{Name: "Test", ModifiedAt: time.Now()}, nil)
+			Expect(result).To(Equal(bson.M{
+				"$set":         bson.M{"name": "Test"},
+				"$currentDate": bson.M{"modifiedAt": true},
+			}))
+		})
+
+		It("should route the field into $currentDate even if it would be omitted", func() {
+			result := ConvertStructToUpdateBSON(currentDateStruct{}, &MappingOpts{GenerateFilterOrPatch: true})
+			Expect(result).To(Equal(bson.M{"$currentDate": bson.M{"modifiedAt": true}}))
+		})
+	})
+
+	It("should route flattened keys into the operator of the field they're held within", func() {
+		type stats struct {
+			Views int `bson:"views,omitempty"`
+			Likes int `bson:"likes,omitempty"`
+		}
+		result := ConvertStructToUpdateBSON(
+			struct {
+				Name  string `bson:"name"`
+				Stats stats  `bson:"stats,inc"`
+			}{Name: "Test", Stats: stats{Views: 1}}, &MappingOpts{UseDotNotation: true},
+		)
+		Expect(result).To(Equal(bson.M{
+			"$set": bson.M{"name": "Test"},
+			"$inc": bson.M{"stats.views": 1},
+		}))
+	})
+
+	Context("with the arrayfilter tag option", func() {
+		type itemPatch struct {
+			Price    int    `bson:"price,omitempty"`
+			Currency string `bson:"currency,omitempty"`
+		}
+
+		It("should target the elements matched by the array filter identifier", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Name  string    `bson:"name"`
+					Items itemPatch `bson:"items,arrayfilter=elem"`
+				}{Name: "Test", Items: itemPatch{Price: 100, Currency: "GBP"}}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$set": bson.M{
+				"name":                   "Test",
+				"items.$[elem].price":    100,
+				"items.$[elem].currency": "GBP",
+			}}))
+		})
+
+		It("should target the first matched element if no identifier is given", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Items itemPatch `bson:"items,arrayfilter"`
+				}{Items: itemPatch{Price: 100}}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$set": bson.M{"items.$.price": 100}}))
+		})
+
+		It("should replace the whole element if the value isn't a nested document", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Tags string `bson:"tags,arrayfilter=tag"`
+				}{Tags: "new"}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$set": bson.M{"tags.$[tag]": "new"}}))
+		})
+
+		It("should route the positional paths into the field's update operator", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Items struct {
+						Quantity int `bson:"quantity"`
+					} `bson:"items,inc,arrayfilter=elem"`
+					Seen time.Time `bson:"seen,currentdate,arrayfilter=elem"`
+				}{Items: struct {
+					Quantity int `bson:"quantity"`
+				}{Quantity: 2}}, nil,
+			)
+			Expect(result).To(Equal(bson.M{
+				"$inc":         bson.M{"items.$[elem].quantity": 2},
+				"$currentDate": bson.M{"seen.$[elem]": true},
+			}))
+		})
+
+		It("should only set the current date on the element itself, even if it holds a nested document", func() {
+			result := ConvertStructToUpdateBSON(
+				struct {
+					Audit struct {
+						Note string `bson:"note"`
+					} `bson:"audit,currentdate,arrayfilter=elem"`
+				}{}, nil,
+			)
+			Expect(result).To(Equal(bson.M{"$currentDate": bson.M{"audit.$[elem]": true}}))
+		})
+
+		It("should return an error if the identifier is invalid", func() {
+			result, err := ConvertStructToUpdateBSONE(
+				struct {
+					Items itemPatch `bson:"items,arrayfilter=Elem"`
+				}{Items: itemPatch{Price: 100}}, nil,
+			)
+			Expect(result).To(BeNil())
+			Expect(errors.Is(err, ErrInvalidUpdateOperator)).To(BeTrue())
+		})
+	})
+
+	Context("with AutoUpdatedAtKey set", func() {
+		It("should stamp the updated at key using the NowFunc", func() {
+			result := ConvertStructToUpdateBSON(testStruct{TestField1: "Test String"}, &MappingOpts{
+				GenerateFilterOrPatch: true,
+				AutoUpdatedAtKey:      "updatedAt",
+				NowFunc:               fixedClock,
+			})
+			Expect(result).To(Equal(bson.M{"$set": bson.M{"testField1": "Test String", "updatedAt": fixedTime}}))
+		})
+
+		It("should stamp the updated at key even if there is nothing else to set", func() {
+			result := ConvertStructToUpdateBSON(testStruct{}, &MappingOpts{
+				GenerateFilterOrPatch: true,
+				AutoUpdatedAtKey:      "updatedAt",
+				NowFunc:               fixedClock,
+			})
+			Expect(result).To(Equal(bson.M{"$set": bson.M{"updatedAt": fixedTime}}))
+		})
+
+		It("should default to the current time in UTC", func() {
+			before := time.Now()
+			result := ConvertStructToUpdateBSON(testStruct{}, &MappingOpts{
+				GenerateFilterOrPatch: true,
+				AutoUpdatedAtKey:      "updatedAt",
+			})
+			stamped := result["$set"].(bson.M)["updatedAt"].(time.Time)
+			Expect(stamped.Location()).To(Equal(time.UTC))
+			Expect(stamped.Before(before)).To(BeFalse())
+		})
+
+		It("should return an error if the key is invalid", func() {
+			result, err := ConvertStructToUpdateBSONE(testStruct{}, &MappingOpts{AutoUpdatedAtKey: "$updatedAt"})
+			Expect(result).To(BeNil())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
+
+var _ = Describe("ConvertStructToUpdatePipeline", func() {
+	type testStruct struct {
+		Name  string            `bson:"name"`
+		Attrs map[string]string `bson:"attrs,inline"`
+	}
+
+	fixedTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixedClock := func() time.Time { return fixedTime }
+
+	It("should set keys containing a literal dot using $setField", func() {
+		result := ConvertStructToUpdatePipeline(
+			testStruct{Name: "Test String", Attrs: map[string]string{"a.b": "dotted"}}, nil,
+		)
+		Expect(result).To(Equal([]bson.D{
+			{{Key: "$set", Value: bson.D{{Key: "name", Value: bson.D{{Key: "$literal", Value: "Test String"}}}}}},
+			{{Key: "$replaceWith", Value: bson.D{{Key: "$setField", Value: bson.D{
+				{Key: "field", Value: bson.D{{Key: "$literal", Value: "a.b"}}},
+				{Key: "input", Value: "$$ROOT"},
+				{Key: "value", Value: bson.D{{Key: "$literal", Value: "dotted"}}},
+			}}}}},
+		}))
+	})
+
+	It("should set keys prefixed with $ using $setField", func() {
+		result := ConvertStructToUpdatePipeline(
+			testStruct{Attrs: map[string]string{"$price": "$10"}}, &MappingOpts{GenerateFilterOrPatch: true},
+		)
+		Expect(result).To(Equal([]bson.D{
+			{{Key: "$replaceWith", Value: bson.D{{Key: "$setField", Value: bson.D{
+				{Key: "field", Value: bson.D{{Key: "$literal", Value: "$price"}}},
+				{Key: "input", Value: "$$ROOT"},
+				{Key: "value", Value: bson.D{{Key: "$literal", Value: "$10"}}},
+			}}}}},
+		}))
+	})
+
+	It("should stamp the updated at key", func() {
+		result := ConvertStructToUpdatePipeline(
+			testStruct{}, &MappingOpts{GenerateFilterOrPatch: true, AutoUpdatedAtKey: "updatedAt", NowFunc: fixedClock},
+		)
+		Expect(result).To(Equal([]bson.D{
+			{{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: bson.D{{Key: "$literal", Value: fixedTime}}}}}},
+		}))
+	})
+
+	It("should return nil if there is nothing to set", func() {
+		result := ConvertStructToUpdatePipeline(testStruct{}, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(result).To(BeNil())
+	})
+
+	It("should still reject keys with a dot when generating an update document", func() {
+		result, err := ConvertStructToUpdateBSONE(testStruct{Attrs: map[string]string{"a.b": "dotted"}}, nil)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrInvalidKey)).To(BeTrue())
+	})
+})
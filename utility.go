@@ -1,12 +1,30 @@
 package mapper
 
-import "reflect"
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"hash"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
 
 // structFields returns a slice of all of the StructFields within a given struct
-func (s *StructToBSON) structFields() []reflect.StructField {
+func (s *StructToBSON) structFields(opts *MappingOpts) []reflect.StructField {
 	t := s.value.Type()
 
-	f := make([]reflect.StructField, 0)
+	f := make([]reflect.StructField, 0, t.NumField())
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
@@ -17,7 +35,7 @@ func (s *StructToBSON) structFields() []reflect.StructField {
 		}
 
 		// Ignoring omitted fields
-		if tag := field.Tag.Get(s.TagName); tag == "-" {
+		if tag := s.fieldTag(field, opts); tag == "-" {
 			continue
 		}
 
@@ -26,20 +44,1101 @@ func (s *StructToBSON) structFields() []reflect.StructField {
 	return f
 }
 
+// fieldTag returns the value of the struct field's tag, or the value of it's FallbackTagName
+// tag if the field doesn't have one
+func (s *StructToBSON) fieldTag(field reflect.StructField, opts *MappingOpts) string {
+	tag := field.Tag.Get(s.TagName)
+	if tag == "" && !s.resetTags && opts != nil && opts.FallbackTagName != "" {
+		return field.Tag.Get(opts.FallbackTagName)
+	}
+	return tag
+}
+
+// fieldByRef returns the value of the struct field referenced by it's name or tag name
+func (s *StructToBSON) fieldByRef(ref string, opts *MappingOpts) (reflect.Value, bool) {
+	for _, field := range s.structFields(opts) {
+		if tagName, _ := parseTag(s.fieldTag(field, opts)); field.Name == ref || tagName == ref {
+			return s.value.FieldByIndex(field.Index), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// lengthOf returns the length of the struct field referenced by it's name or tag name, converted to the type
+// of the field which holds it. A nil pointer is treated as having a length of zero
+func (s *StructToBSON) lengthOf(ref string, typ reflect.Type, opts *MappingOpts) (reflect.Value, error) {
+	val, ok := s.fieldByRef(ref, opts)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("no field named %q: %w", ref, ErrInvalidLengthField)
+	}
+
+	n := 0
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Invalid:
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		n = val.Len()
+	default:
+		return reflect.Value{}, fmt.Errorf("type %s has no length: %w", val.Type(), ErrInvalidLengthField)
+	}
+
+	if !isInteger(reflect.Zero(typ).Interface()) {
+		return reflect.Value{}, fmt.Errorf("type %s isn't an integer: %w", typ, ErrInvalidLengthField)
+	}
+	return reflect.ValueOf(n).Convert(typ), nil
+}
+
+// eachFieldKey calls the function with the resolved key and tag options of every struct field,
+// skipping any fields whose key is invalid as they would have already caused the mapping to fail
+func (s *StructToBSON) eachFieldKey(opts *MappingOpts, fn func(key string, tagOpts tagOptions)) {
+	for _, field := range s.structFields(opts) {
+		if key, tagOpts, ok := s.fieldKey(field, opts); ok {
+			fn(key, tagOpts)
+		}
+	}
+}
+
+// fieldKey returns the resolved key and tag options of the struct field, returning false
+// if the key is invalid
+func (s *StructToBSON) fieldKey(field reflect.StructField, opts *MappingOpts) (string, tagOptions, bool) {
+	tagName, tagOpts := parseTag(s.fieldTag(field, opts))
+
+	name := field.Name
+	if tagName != "" {
+		name = tagName
+	} else {
+		name = opts.defaultKeyCase(name)
+	}
+
+	key, err := resolveKey(s.renameKey(name, opts), opts)
+	return key, tagOpts, err == nil
+}
+
+// setElem sets the value of the key within the document, if the key is already
+// present its value is replaced, otherwise it is appended to the end of the document
+func setElem(doc bson.D, key string, val interface{}) bson.D {
+	for i := range doc {
+		if doc[i].Key == key {
+			doc[i].Value = val
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: val})
+}
+
+// hasElem checks whether the key is present within the document
+func hasElem(doc bson.D, key string) bool {
+	for _, e := range doc {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// hasID checks whether the document holds a non-zero "_id"
+func hasID(doc bson.D) bool {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value != nil && !reflect.ValueOf(e.Value).IsZero()
+		}
+	}
+	return false
+}
+
+// promotedElems returns the elements of a nested data structure which
+// is being pulled up into it's parent ("flatten" or "inline")
+//
+// The elements of maps are sorted by their key, so that they're promoted deterministically
+func promotedElems(val interface{}) bson.D {
+	switch v := val.(type) {
+	case bson.D:
+		return v
+	case bson.M:
+		d := make(bson.D, 0, len(v))
+		for k, e := range v {
+			d = append(d, bson.E{Key: k, Value: e})
+		}
+		sort.Slice(d, func(i, j int) bool { return d[i].Key < d[j].Key })
+		return d
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+
+	d := make(bson.D, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		d = append(d, bson.E{Key: k.String(), Value: v.MapIndex(k).Interface()})
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i].Key < d[j].Key })
+	return d
+}
+
+// canPromote returns whether the elements of a nested data structure can be pulled up
+// into it's parent, only documents and maps with string keys can be promoted
+func canPromote(val interface{}) bool {
+	if isDoc(val) {
+		return true
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(val))
+	return v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String
+}
+
+// dottedElems returns the elements of a mapped nested document which are flattened into dot separated
+// paths (see UseDotNotation). The elements of a mapped slice of structs are addressed by their index
+func dottedElems(val interface{}) bson.D {
+	slice, ok := val.([]interface{})
+	if !ok {
+		return promotedElems(val)
+	}
+
+	var out bson.D
+	for i, elem := range slice {
+		index := strconv.Itoa(i)
+		if elems := promotedElems(elem); len(elems) > 0 {
+			for _, e := range elems {
+				out = append(out, bson.E{Key: joinPath(index, e.Key), Value: e.Value})
+			}
+			continue
+		}
+		out = append(out, bson.E{Key: index, Value: elem})
+	}
+	return out
+}
+
+// setGroupedElem sets the value of the key within the document held under the group's key,
+// creating the group's document if needed. If the group is empty, the value is set directly
+// within the document.
+func setGroupedElem(doc bson.D, group string, key string, val interface{}) bson.D {
+	if group == "" {
+		return setElem(doc, key, val)
+	}
+
+	for _, e := range doc {
+		if m, ok := e.Value.(bson.M); ok && e.Key == group {
+			m[key] = val
+			return doc
+		}
+	}
+	return setElem(doc, group, bson.M{key: val})
+}
+
+// limitString enforces the MaxStringLen on a string (or pointer to a string) value, including the strings
+// held within a slice, array or map, either truncating them or returning an error which reports the key
+// (or path, ie. "tags.1") they're held under
+func limitString(key string, val interface{}, opts *MappingOpts) (interface{}, error) {
+	if opts == nil || opts.MaxStringLen <= 0 {
+		return val, nil
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		limited, err := limitElems(key, reflect.ValueOf(val), opts)
+		if err != nil || !limited.IsValid() {
+			return val, err
+		}
+		return limited.Interface(), nil
+	}
+
+	if v.Kind() != reflect.String || v.Len() <= opts.MaxStringLen {
+		return val, nil
+	}
+
+	if !opts.TruncateLongStrings {
+		return nil, fmt.Errorf("value at %q exceeds the maximum string length of %d: %w", key, opts.MaxStringLen, ErrStringTooLong)
+	}
+
+	// Avoid splitting a multi-byte character in two
+	str := v.String()
+	end := opts.MaxStringLen
+	for end > 0 && !utf8.RuneStart(str[end]) {
+		end--
+	}
+	return str[:end], nil
+}
+
+// limitElems enforces the MaxStringLen on the strings held within a slice, array or map (see limitString),
+// including any nested within them. If any of the strings are truncated a copy of the same type is returned,
+// otherwise an invalid reflect.Value is returned as the original can be used as it is
+func limitElems(key string, v reflect.Value, opts *MappingOpts) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return reflect.Value{}, nil
+		}
+		limited, err := limitElems(key, v.Elem(), opts)
+		if err != nil || !limited.IsValid() || v.Kind() == reflect.Interface {
+			return limited, err
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(limited)
+		return p, nil
+
+	case reflect.String:
+		limited, err := limitString(key, v.String(), opts)
+		if err != nil || limited == v.String() {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(limited).Convert(v.Type()), nil
+
+	case reflect.Slice, reflect.Array:
+		// Byte slices are binary data rather than strings
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.Value{}, nil
+		}
+
+		var out reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			limited, err := limitElems(joinPath(key, strconv.Itoa(i)), v.Index(i), opts)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if !limited.IsValid() {
+				continue
+			}
+			if !out.IsValid() {
+				out = reflect.New(v.Type()).Elem()
+				if v.Kind() == reflect.Slice {
+					out.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+				}
+				reflect.Copy(out, v)
+			}
+			out.Index(i).Set(limited)
+		}
+		return out, nil
+
+	case reflect.Map:
+		var out reflect.Value
+		for _, k := range v.MapKeys() {
+			limited, err := limitElems(joinPath(key, fmt.Sprint(k.Interface())), v.MapIndex(k), opts)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if !limited.IsValid() {
+				continue
+			}
+			if !out.IsValid() {
+				out = reflect.MakeMapWithSize(v.Type(), v.Len())
+				for _, c := range v.MapKeys() {
+					out.SetMapIndex(c, v.MapIndex(c))
+				}
+			}
+			out.SetMapIndex(k, limited)
+		}
+		return out, nil
+	}
+	return reflect.Value{}, nil
+}
+
+// checkOneOf checks that the value (or the value a pointer points to) is one of the space separated allowed values,
+// a nil pointer is always allowed as there is no value to check
+func checkOneOf(val reflect.Value, allowed string) error {
+	v := reflect.Indirect(val)
+	if !v.IsValid() {
+		return nil
+	}
+
+	str := fmt.Sprint(v.Interface())
+	for _, a := range strings.Fields(allowed) {
+		if str == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("holds %q which isn't one of %q: %w", str, allowed, ErrNotOneOf)
+}
+
+// stringTransforms maps the tag options which transform a string to the function which transforms it,
+// in the order they're applied
+var stringTransforms = []struct {
+	opt string
+	fn  func(string) string
+}{
+	{"trim", strings.TrimSpace},
+	{"lower", strings.ToLower},
+	{"upper", strings.ToUpper},
+}
+
+// transformString returns a copy of a string (or a pointer to one) transformed by the function,
+// any other value is returned as is
+func transformString(val reflect.Value, fn func(string) string) reflect.Value {
+	switch {
+	case val.Kind() == reflect.String:
+		return reflect.ValueOf(fn(val.String())).Convert(val.Type())
+	case val.Kind() == reflect.Ptr && !val.IsNil() && val.Elem().Kind() == reflect.String:
+		p := reflect.New(val.Type().Elem())
+		p.Elem().Set(transformString(val.Elem(), fn))
+		return p
+	}
+	return val
+}
+
+// minSize converts an integer (or a pointer to one) into an int32 if it fits within 32 bits,
+// the same as the Mongo-Go Driver does for fields with the "minsize" struct tag option
+func minSize(val interface{}) interface{} {
+	v := reflect.Indirect(reflect.ValueOf(val))
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64:
+		if v.Int() >= math.MinInt32 && v.Int() <= math.MaxInt32 {
+			return int32(v.Int())
+		}
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		if v.Uint() <= math.MaxInt32 {
+			return int32(v.Uint())
+		}
+	}
+	return val
+}
+
+// roundFloat rounds a float (or a pointer to one) to the number of decimal places,
+// any other value is returned as it is
+func roundFloat(val interface{}, places int) interface{} {
+	v := reflect.Indirect(reflect.ValueOf(val))
+	if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+		return val
+	}
+
+	// If scaling the value overflows, it already has no decimal places to round
+	pow := math.Pow10(places)
+	scaled := v.Float() * pow
+	if math.IsInf(scaled, 0) || math.IsNaN(scaled) {
+		return val
+	}
+
+	rounded := math.Round(scaled) / pow
+	if v.Kind() == reflect.Float32 {
+		return float32(rounded)
+	}
+	return rounded
+}
+
+// stringify returns the String representation of a fmt.Stringer, a []rune (or pointer to one) as a string, or a
+// time.Time (or pointer to one) formatted with the TimeLayout if it's set. It returns false if the value isn't
+// one of those or is a nil pointer, as it's String method may dereference it
+func (opts *MappingOpts) stringify(val reflect.Value) (string, bool) {
+	if isNil(val) {
+		return "", false
+	}
+
+	v := interfaceOf(val)
+	switch r := v.(type) {
+	case []rune:
+		return string(r), true
+	case *[]rune:
+		return string(*r), true
+	}
+
+	if opts != nil && opts.TimeLayout != "" {
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(opts.TimeLayout), true
+		case *time.Time:
+			return t.Format(opts.TimeLayout), true
+		}
+	}
+
+	if stringer, ok := v.(fmt.Stringer); ok {
+		return stringer.String(), true
+	}
+	return "", false
+}
+
+// toTimestamp converts a time.Time (using it's Unix seconds) or a uint64 (holding the seconds
+// in the high 32 bits and the ordinal in the low 32 bits) into a primitive.Timestamp
+func toTimestamp(val interface{}) (primitive.Timestamp, bool) {
+	switch v := val.(type) {
+	case time.Time:
+		return primitive.Timestamp{T: uint32(v.Unix())}, true
+	case *time.Time:
+		if v != nil {
+			return toTimestamp(*v)
+		}
+	case uint64:
+		return primitive.Timestamp{T: uint32(v >> 32), I: uint32(v)}, true
+	case *uint64:
+		if v != nil {
+			return toTimestamp(*v)
+		}
+	}
+	return primitive.Timestamp{}, false
+}
+
+// toDBRef wraps the id in a DBRef to the document in the collection, ie. { "$ref": collection, "$id": id }.
+// The "$ref" must come first, so the DBRef is a bson.D. Nil ids are left as nil rather than being wrapped
+func toDBRef(collection string, id interface{}) interface{} {
+	if isNil(reflect.ValueOf(id)) {
+		return id
+	}
+	return bson.D{{Key: "$ref", Value: collection}, {Key: "$id", Value: id}}
+}
+
+// toChar converts a rune (or a pointer to one) into a single character string
+func toChar(val reflect.Value) (string, bool) {
+	v := reflect.Indirect(val)
+	if v.Kind() != reflect.Int32 {
+		return "", false
+	}
+	return string(rune(v.Int())), true
+}
+
+// toNumber parses a numeric string (or a pointer to one) into an int64, or a float64 if it isn't
+// an integer. It returns false if the value isn't a string, or an error if it isn't a valid number
+func toNumber(val reflect.Value) (interface{}, bool, error) {
+	v := reflect.Indirect(val)
+	if v.Kind() != reflect.String {
+		return nil, false, nil
+	}
+
+	str := v.String()
+	if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return i, true, nil
+	}
+	if f, err := strconv.ParseFloat(str, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f, true, nil
+	}
+	return nil, true, fmt.Errorf("%q isn't a valid number: %w", str, ErrInvalidNumber)
+}
+
+// hashAlgorithms maps the algorithms supported by the "hash=algo" tag option to their constructor
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hashString hashes a string (or a pointer to one) with the algorithm, returning the hex digest.
+// It returns false if the value isn't a string, or an error if the algorithm isn't supported
+func hashString(val reflect.Value, algo string) (string, bool, error) {
+	newHash, ok := hashAlgorithms[algo]
+	if !ok {
+		return "", false, fmt.Errorf("%q: %w", algo, ErrUnsupportedHash)
+	}
+
+	v := reflect.Indirect(val)
+	if v.Kind() != reflect.String {
+		return "", false, nil
+	}
+
+	h := newHash()
+	h.Write([]byte(v.String()))
+	return hex.EncodeToString(h.Sum(nil)), true, nil
+}
+
+// byteEncodings holds the tag options which encode a byte slice or array as a string, along
+// with the function which encodes it. If a field has more than one, the first listed is used
+var byteEncodings = []struct {
+	opt    string
+	encode func([]byte) string
+}{
+	{"hex", hex.EncodeToString},
+	{"base64", base64.StdEncoding.EncodeToString},
+	{"base64url", base64.URLEncoding.EncodeToString},
+}
+
+// encodeBytes encodes a byte slice or array (or a pointer to one) as a string,
+// if the field has one of the byte encoding tag options
+func encodeBytes(val reflect.Value, tagOpts tagOptions) (string, bool) {
+	var encode func([]byte) string
+	for _, e := range byteEncodings {
+		if tagOpts.Has(e.opt) {
+			encode = e.encode
+			break
+		}
+	}
+	if encode == nil || !val.IsValid() {
+		return "", false
+	}
+
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return "", false
+		}
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		if val.Type().Elem().Kind() != reflect.Uint8 {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	b := make([]byte, val.Len())
+	reflect.Copy(reflect.ValueOf(b), val)
+	return encode(b), true
+}
+
+// dropNilElems returns a copy of a slice or array (or a pointer to one) without any of it's nil elements,
+// any other value is returned as is
+func dropNilElems(val interface{}) interface{} {
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return val
+	}
+
+	out := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if elem := v.Index(i); !isNil(elem) {
+			out = reflect.Append(out, elem)
+		}
+	}
+	return out.Interface()
+}
+
+// dedupeElems returns a copy of a slice or array (or a pointer to one) without any duplicates of it's bool,
+// number or string elements, preserving the order they first appear in. Any other elements are kept as
+// they are, and if there aren't any duplicates (or it's a byte slice) the value is returned as is
+func dedupeElems(val interface{}) interface{} {
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return val
+	}
+
+	seen := make(map[interface{}]struct{}, v.Len())
+	out := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+
+		// Elements held within an interface are compared by the value they hold
+		e := elem
+		if e.Kind() == reflect.Interface && !e.IsNil() {
+			e = e.Elem()
+		}
+		if isScalarKind(e.Kind()) {
+			if _, ok := seen[e.Interface()]; ok {
+				continue
+			}
+			seen[e.Interface()] = struct{}{}
+		}
+		out = reflect.Append(out, elem)
+	}
+
+	if out.Len() == v.Len() {
+		return val
+	}
+	return out.Interface()
+}
+
+// isNil checks whether the value is nil, including a nil pointer held within an interface
+func isNil(val reflect.Value) bool {
+	if val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return val.IsNil()
+	}
+	return false
+}
+
+// keyBy converts a mapped slice of structs into a bson.M, where each element is held under
+// the value of it's field. If multiple elements hold the same value, the last one is kept
+func keyBy(key string, val interface{}, field string, opts *MappingOpts) (bson.M, error) {
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("field %q of type %T can't be keyed by %q: %w", key, val, field, ErrMissingKeyField)
+	}
+
+	out := make(bson.M, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i).Interface()
+		id, ok := docValue(elem, field)
+		if !ok || id == nil {
+			return nil, fmt.Errorf("element %d of %q has no %q field: %w", i, key, field, ErrMissingKeyField)
+		}
+
+		var k string
+		if oid, ok := id.(primitive.ObjectID); ok {
+			k = oid.Hex()
+		} else {
+			k = fmt.Sprint(id)
+		}
+
+		k, err := resolveMapKey(k, opts)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = elem
+	}
+	return out, nil
+}
+
+// docValue returns the value of the key within a mapped document (either a bson.M or bson.D)
+func docValue(doc interface{}, key string) (interface{}, bool) {
+	switch d := doc.(type) {
+	case bson.M:
+		val, ok := d[key]
+		return val, ok
+	case bson.D:
+		for _, e := range d {
+			if e.Key == key {
+				return e.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// toList converts a mapped map of structs into a slice of the structs sorted by their
+// map key, where each of the structs holds it's map key under the field
+func toList(key string, val interface{}, field string) ([]interface{}, error) {
+	if val == nil || (reflect.ValueOf(val).Kind() == reflect.Ptr && reflect.ValueOf(val).IsNil()) {
+		return nil, nil
+	}
+
+	m, ok := val.(bson.M)
+	if !ok {
+		return nil, fmt.Errorf("field %q of type %T can't be converted to a list", key, val)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]interface{}, 0, len(m))
+	for _, k := range keys {
+		switch elem := m[k].(type) {
+		case bson.M:
+			// Copy the element, so the key isn't injected into a map which may be shared
+			e := make(bson.M, len(elem)+1)
+			for ek, ev := range elem {
+				e[ek] = ev
+			}
+			e[field] = k
+			out = append(out, e)
+		case bson.D:
+			e := make(bson.D, len(elem), len(elem)+1)
+			copy(e, elem)
+			out = append(out, setElem(e, field, k))
+		default:
+			return nil, fmt.Errorf("element %q of %q of type %T can't be converted to a list", k, key, m[k])
+		}
+	}
+	return out, nil
+}
+
+// toObjectID converts a hex string or 12 byte slice (or a pointer to either) into a primitive.ObjectID.
+// Empty strings and nil pointers are treated as null
+func toObjectID(val reflect.Value) (interface{}, error) {
+	v := reflect.Indirect(val)
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if id, ok := v.Interface().(primitive.ObjectID); ok {
+		return id, nil
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		if v.Len() == 0 {
+			return nil, nil
+		}
+		id, err := primitive.ObjectIDFromHex(v.String())
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a valid hex ObjectID: %w", v.String(), ErrInvalidObjectID)
+		}
+		return id, nil
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		var id primitive.ObjectID
+		if v.Len() != len(id) {
+			return nil, fmt.Errorf("%d bytes can't be an ObjectID: %w", v.Len(), ErrInvalidObjectID)
+		}
+		reflect.Copy(reflect.ValueOf(id[:]), v)
+		return id, nil
+	}
+	return nil, fmt.Errorf("type %s can't be an ObjectID: %w", v.Type(), ErrInvalidObjectID)
+}
+
+// coerceObjectID converts a 24 character hex string (or a pointer to one) into a primitive.ObjectID
+// if CoerceObjectID is set, any other value (or a string which isn't a valid ObjectID) is returned as is
+func (opts *MappingOpts) coerceObjectID(val interface{}) interface{} {
+	if opts == nil || !opts.CoerceObjectID {
+		return val
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(val))
+	if v.Kind() != reflect.String || v.Len() != 24 {
+		return val
+	}
+	if id, err := primitive.ObjectIDFromHex(v.String()); err == nil {
+		return id
+	}
+	return val
+}
+
+// isDoc checks whether the value is a mapped document (either a bson.M or bson.D)
+func isDoc(val interface{}) bool {
+	switch val.(type) {
+	case bson.M, bson.D:
+		return true
+	}
+	return false
+}
+
+// interfaceOf returns the value held by the reflect.Value as an interface{},
+// treating an invalid value as nil rather than panicking
+func interfaceOf(val reflect.Value) interface{} {
+	if !val.IsValid() {
+		return nil
+	}
+	return val.Interface()
+}
+
+// hasStructFields checks whether the struct held in the value has any fields which
+// can be mapped, as opposed to a struct such as time.Time which is treated as a value
+func (s *StructToBSON) hasStructFields(v reflect.Value, opts *MappingOpts) bool {
+	n := &StructToBSON{value: v, TagName: s.tagNameFor(v.Type(), opts), resetTags: s.resetTags}
+	return len(n.structFields(opts)) > 0
+}
+
+// tagNameFor returns the tag name which should be parsed for a nested struct of the type,
+// falling back to the tag name of the top level struct if TagNameByType doesn't hold it
+func (s *StructToBSON) tagNameFor(t reflect.Type, opts *MappingOpts) string {
+	if opts != nil {
+		if tag, ok := opts.TagNameByType[t]; ok {
+			return tag
+		}
+	}
+	return s.globalTagName()
+}
+
+// globalTagName returns the tag name of the top level struct being mapped
+func (s *StructToBSON) globalTagName() string {
+	if s.rootTagName != "" {
+		return s.rootTagName
+	}
+	return s.TagName
+}
+
 // structVal checks if the argument is a struct or a pointer to a struct
 // if so it returns the reflected value of the struct
 //
 // Panics if a struct || *struct is not passed to the function
 func structVal(s interface{}) reflect.Value {
+	v, err := structValE(s)
+	if err != nil {
+		panic("not struct")
+	}
+	return v
+}
+
+// structValE behaves the same as structVal, however rather than panicking it returns an error
+// wrapping ErrNotAStruct (which reports the kind that was passed) if the argument isn't a struct,
+// or ErrNilStruct (which also wraps ErrNotAStruct) if it's a nil pointer to a struct
+func structValE(s interface{}) (reflect.Value, error) {
 	v := reflect.ValueOf(s)
 
 	for v.Kind() == reflect.Ptr {
+		// A nil pointer to a struct is the right kind, however there is nothing to map
+		if v.IsNil() && v.Type().Elem().Kind() == reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("expected struct or pointer to struct, got nil %s: %w", v.Type(), ErrNilStruct)
+		}
 		v = v.Elem()
 	}
 
 	if v.Kind() != reflect.Struct {
-		panic("not struct")
+		return reflect.Value{}, fmt.Errorf("expected struct or pointer to struct, got %s: %w", reflect.ValueOf(s).Kind(), ErrNotAStruct)
 	}
 
-	return v
+	return v, nil
+}
+
+// checkStruct returns an error if the argument is not a struct or pointer to a struct
+func checkStruct(s interface{}) error {
+	_, err := structValE(s)
+	return err
+}
+
+// validateKey checks that a resolved key is one that MongoDB can safely store
+//
+// Returns an error wrapping ErrInvalidKey if it is not
+func validateKey(key string, opts *MappingOpts) error {
+	// Keys containing a "." or prefixed with "$" can be set using "$setField" in an update pipeline
+	setField := opts != nil && opts.allowSetFieldKeys
+
+	switch {
+	case strings.HasPrefix(key, "$") && !setField:
+		return fmt.Errorf("key %q is prefixed with \"$\": %w", key, ErrInvalidKey)
+	case strings.Contains(key, ".") && !setField:
+		return fmt.Errorf("key %q contains a \".\": %w", key, ErrInvalidKey)
+	}
+	return validateMapKey(key, opts)
+}
+
+// validateMapKey checks that a key taken from the data being mapped (ie. the key of a map) is one that
+// MongoDB can store. As these keys are passed through as they are, only null bytes and the MaxKeyLength
+// are checked, so that an operator within a bson.M filter can be passed through
+//
+// Returns an error wrapping ErrInvalidKey if it is not
+func validateMapKey(key string, opts *MappingOpts) error {
+	switch {
+	case strings.ContainsRune(key, '\x00'):
+		return fmt.Errorf("key %q contains a null byte: %w", key, ErrInvalidKey)
+	case opts != nil && opts.MaxKeyLength > 0 && len(key) > opts.MaxKeyLength:
+		return fmt.Errorf("key %q exceeds the maximum key length of %d: %w", key, opts.MaxKeyLength, ErrInvalidKey)
+	}
+	return nil
+}
+
+// renameKey applies the RenameKeys option to the key, only renaming
+// the keys of nested structs if RenameKeysRecursive is set
+func (s *StructToBSON) renameKey(key string, opts *MappingOpts) string {
+	if opts == nil || (s.nested && !opts.RenameKeysRecursive) {
+		return key
+	}
+
+	if renamed, ok := opts.RenameKeys[key]; ok {
+		return renamed
+	}
+	return key
+}
+
+// resolveKey passes the key through the KeySanitizer (if one has been set) and
+// then validates the result
+func resolveKey(key string, opts *MappingOpts) (string, error) {
+	if opts != nil && opts.KeySanitizer != nil {
+		sanitized, err := opts.KeySanitizer(key)
+		if err != nil {
+			return "", fmt.Errorf("unable to sanitize key %q: %w", key, err)
+		}
+		key = sanitized
+	}
+
+	if err := validateKey(key, opts); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// resolveMapKey passes a key which is taken from the data being mapped (ie. the key of a map) through the
+// KeySanitizer (if one has been set) and then validates the result (see validateMapKey). Unless an update
+// document is being generated, keys prefixed with "$" or containing a "." are passed through as they are
+func resolveMapKey(key string, opts *MappingOpts) (string, error) {
+	if opts != nil && opts.validateMapKeys {
+		return resolveKey(key, opts)
+	}
+
+	if opts != nil && opts.KeySanitizer != nil {
+		sanitized, err := opts.KeySanitizer(key)
+		if err != nil {
+			return "", fmt.Errorf("unable to sanitize key %q: %w", key, err)
+		}
+		key = sanitized
+	}
+
+	if err := validateMapKey(key, opts); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// resolveMapKeys resolves all of the keys within a map which is being passed as is (see resolveMapKey)
+//
+// If none of the keys are changed by the KeySanitizer the original map is returned,
+// otherwise a bson.M copy of the map holding the sanitized keys is returned
+func resolveMapKeys(v reflect.Value, opts *MappingOpts) (interface{}, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return v.Interface(), nil
+	}
+
+	keys := v.MapKeys()
+	resolved := make([]string, len(keys))
+	changed := false
+	for i, k := range keys {
+		key, err := resolveMapKey(k.String(), opts)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = key
+		changed = changed || key != k.String()
+	}
+
+	if !changed {
+		return v.Interface(), nil
+	}
+
+	m := make(bson.M, len(keys))
+	for i, k := range keys {
+		m[resolved[i]] = v.MapIndex(k).Interface()
+	}
+	return m, nil
+}
+
+// leafValue returns the value of a type the Mongo-Go Driver has dedicated support for (see isDriverLeaf),
+// converting a time.Time (or pointer to one) to a primitive.DateTime if ConvertTimeToDateTime is set
+func (opts *MappingOpts) leafValue(val reflect.Value) interface{} {
+	if opts == nil || !opts.ConvertTimeToDateTime {
+		return interfaceOf(val)
+	}
+	return toDateTime(interfaceOf(val))
+}
+
+// toDateTime converts a time.Time (or a non-nil pointer to one) to a primitive.DateTime,
+// any other value is returned as is
+func toDateTime(val interface{}) interface{} {
+	switch t := val.(type) {
+	case time.Time:
+		return primitive.NewDateTimeFromTime(t)
+	case *time.Time:
+		if t != nil {
+			return primitive.NewDateTimeFromTime(*t)
+		}
+	}
+	return val
+}
+
+// toDateTimes converts any time.Time (or pointer to one) held within the slice, array or map (including
+// within any nested slices, arrays or maps) to a primitive.DateTime. Those which may hold one are copied
+// into a []interface{} or bson.M (keeping a bson.D as it is), any other value is returned as is
+func toDateTimes(val interface{}) interface{} {
+	if d, ok := val.(bson.D); ok {
+		out := make(bson.D, len(d))
+		for i, e := range d {
+			out[i] = bson.E{Key: e.Key, Value: toDateTimes(e.Value)}
+		}
+		return out
+	}
+
+	v := reflect.ValueOf(val)
+	if !v.IsValid() || !mayHoldTime(v.Type()) {
+		return val
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return val
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = toDateTimes(v.Index(i).Interface())
+		}
+		return out
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.IsNil() {
+			return val
+		}
+		out := make(bson.M, v.Len())
+		for _, k := range v.MapKeys() {
+			out[k.String()] = toDateTimes(v.MapIndex(k).Interface())
+		}
+		return out
+	}
+	return toDateTime(val)
+}
+
+// mayHoldTime checks whether a value of the type may hold a time.Time, either directly or within an interface
+func mayHoldTime(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return mayHoldTime(t.Elem())
+	}
+	return t == reflect.TypeOf(time.Time{})
+}
+
+// isNullTime checks whether the value is a time.Time (or a pointer to one)
+// that is equal to the sentinel "null" time
+func isNullTime(val reflect.Value, nullTime time.Time) bool {
+	if nullTime.IsZero() || !val.IsValid() {
+		return false
+	}
+
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return false
+		}
+		val = val.Elem()
+	}
+
+	t, ok := val.Interface().(time.Time)
+	return ok && t.Equal(nullTime)
+}
+
+// isBSONNull checks whether the value is a primitive.Null, including one held within an interface or pointer
+func isBSONNull(val reflect.Value) bool {
+	for val.Kind() == reflect.Interface || val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return false
+		}
+		val = val.Elem()
+	}
+	_, ok := interfaceOf(val).(primitive.Null)
+	return ok
+}
+
+// isDriverLeaf checks whether the value is one of the types which the Mongo-Go Driver has dedicated
+// support for (see isDriverType), including one held within an interface or pointer
+func isDriverLeaf(val reflect.Value) bool {
+	for val.Kind() == reflect.Interface || val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return false
+		}
+		if isDriverType(val.Interface()) {
+			return true
+		}
+		val = val.Elem()
+	}
+	return val.IsValid() && isDriverType(val.Interface())
+}
+
+// isEmpty checks whether the value should be omitted as empty when the "omitempty" tag option
+// or GenerateFilterOrPatch applies
+func isEmpty(val reflect.Value, opts *MappingOpts) bool {
+	if !val.IsValid() || val.IsZero() {
+		return true
+	}
+
+	// Handling edge cases that reflect.value.IsZero doesn't catch
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map:
+		if val.Len() == 0 {
+			return true
+		}
+	}
+
+	if opts != nil && isNullTime(val, opts.NullTime) {
+		return true
+	}
+
+	// A pointer to a zero time is just as empty as a nil pointer, as is
+	// an explicit BSON null regardless of how it's held
+	return isZeroTimePtr(val) || isBSONNull(val)
+}
+
+// isZeroTimePtr checks whether the value is a non-nil *time.Time which points at the zero time
+func isZeroTimePtr(val reflect.Value) bool {
+	t, ok := interfaceOf(val).(*time.Time)
+	return ok && t != nil && t.IsZero()
 }
@@ -27,7 +27,7 @@ var _ = Describe("structFields", func() {
 				testField5: true,
 			})
 
-		result := testStruct.structFields()
+		result := testStruct.structFields(nil)
 
 		Expect(len(result)).To(Equal(3))
 	})
@@ -48,7 +48,7 @@ var _ = Describe("structFields", func() {
 				testField5: true,
 			})
 
-		result := testStruct.structFields()
+		result := testStruct.structFields(nil)
 
 		Expect(len(result)).To(Equal(2))
 	})
@@ -113,3 +113,22 @@ var _ = Describe("structVal", func() {
 		)
 	})
 })
+
+var _ = Describe("validateKey", func() {
+	DescribeTable("should accept", func(key string, opts *MappingOpts) {
+		Expect(validateKey(key, opts)).To(BeNil())
+	},
+		Entry("a regular key", "testField1", nil),
+		Entry("a key with a $ that isn't a prefix", "test$Field1", nil),
+		Entry("a key equal to the MaxKeyLength", "testField1", &MappingOpts{MaxKeyLength: 10}),
+	)
+
+	DescribeTable("should reject", func(key string, opts *MappingOpts) {
+		Expect(validateKey(key, opts)).NotTo(BeNil())
+	},
+		Entry("a key containing a null byte", "test\x00Field1", nil),
+		Entry("a key prefixed with $", "$testField1", nil),
+		Entry("a key containing a .", "test.Field1", nil),
+		Entry("a key exceeding the MaxKeyLength", "testField10", &MappingOpts{MaxKeyLength: 10}),
+	)
+})
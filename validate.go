@@ -0,0 +1,100 @@
+package mapper
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"time"
+)
+
+// validateEncodable recursively walks the value and checks that everything within
+// it is a type that the Mongo-Go Driver is able to encode
+//
+// The path is the dot separated location of the value within the document,
+// it is only used to make the returned error more helpful
+func validateEncodable(path string, val interface{}) error {
+	if val == nil || isDriverType(val) {
+		return nil
+	}
+
+	if d, ok := val.(bson.D); ok {
+		for _, e := range d {
+			if err := validateEncodable(joinPath(path, e.Key), e.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validateEncodable(path, v.Elem().Interface())
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map at %q has keys of type %s: %w", path, v.Type().Key(), ErrNotEncodable)
+		}
+		for _, k := range v.MapKeys() {
+			if err := validateEncodable(joinPath(path, k.String()), v.MapIndex(k).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateEncodable(joinPath(path, fmt.Sprint(i)), v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Struct:
+		// Structs which haven't been mapped (ie. "omitnested") are encoded by the driver
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" || field.Tag.Get("bson") == "-" {
+				continue
+			}
+			if err := validateEncodable(joinPath(path, field.Name), v.Field(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("value at %q is of type %s: %w", path, v.Type(), ErrNotEncodable)
+}
+
+// isDriverType checks whether the value is one of the types which the Mongo-Go Driver
+// has dedicated support for (ie. time.Time, primitive.ObjectID, or a bson.Marshaler)
+func isDriverType(val interface{}) bool {
+	switch val.(type) {
+	case time.Time, primitive.ObjectID, primitive.Decimal128, primitive.Binary,
+		primitive.DateTime, primitive.Timestamp, primitive.Null, primitive.Undefined,
+		primitive.Regex, primitive.DBPointer, primitive.JavaScript, primitive.Symbol,
+		primitive.CodeWithScope, primitive.MinKey, primitive.MaxKey,
+		bson.Marshaler, bson.ValueMarshaler:
+		return true
+	}
+	return false
+}
+
+// joinPath appends the key to the dot separated path
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
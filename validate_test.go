@@ -0,0 +1,93 @@
+package mapper
+
+import (
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+var _ = Describe("validateEncodable", func() {
+	DescribeTable("should accept", func(val interface{}) {
+		Expect(validateEncodable("", val)).To(BeNil())
+	},
+		Entry("a nil value", nil),
+		Entry("a string", "Test String"),
+		Entry("an int", 123),
+		Entry("a float", 10.1),
+		Entry("a bool", true),
+		Entry("a time", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Entry("an ObjectID", primitive.NewObjectID()),
+		Entry("a Decimal128", primitive.NewDecimal128(1, 1)),
+		Entry("a Binary", primitive.Binary{Data: []byte{1, 2, 3}}),
+		Entry("a byte slice", []byte{1, 2, 3}),
+		Entry("a pointer to a string", new(string)),
+		Entry("a nil pointer", (*int)(nil)),
+		Entry("a bson.M of scalars", bson.M{"Test 1": 1, "Test 2": []string{"Test String"}}),
+		Entry("a slice of interfaces", []interface{}{1, "Test String", bson.M{"Test 1": 1}}),
+		Entry("a struct", struct{ TestField1 string }{TestField1: "Test String"}),
+	)
+
+	DescribeTable("should reject", func(val interface{}) {
+		err := validateEncodable("", val)
+		Expect(errors.Is(err, ErrNotEncodable)).To(BeTrue())
+	},
+		Entry("a channel", make(chan int)),
+		Entry("a function", func() {}),
+		Entry("a complex number", complex(1, 1)),
+		Entry("a map with non string keys", map[int]string{1: "Test String"}),
+		Entry("a channel nested in a bson.M", bson.M{"Test 1": bson.M{"Test 2": make(chan int)}}),
+		Entry("a function nested in a slice", []interface{}{1, func() {}}),
+		Entry("a channel in a struct", struct{ TestField1 chan int }{TestField1: make(chan int)}),
+	)
+
+	It("should include the path of the value in the error", func() {
+		err := validateEncodable("", bson.M{"Test 1": []interface{}{1, make(chan int)}})
+		Expect(err.Error()).To(ContainSubstring("Test 1.1"))
+	})
+})
+
+var _ = Describe("The ValidateEncodable option", func() {
+	It("should return an error for a boxed channel", func() {
+		result, err := ConvertStructToBSONMapE(
+			struct {
+				TestField1 string      `bson:"testField1"`
+				TestField2 interface{} `bson:"testField2"`
+			}{
+				TestField1: "Test String",
+				TestField2: make(chan int),
+			}, &MappingOpts{ValidateEncodable: true},
+		)
+		Expect(result).To(BeNil())
+		Expect(errors.Is(err, ErrNotEncodable)).To(BeTrue())
+	})
+
+	It("should not return an error for encodable values", func() {
+		result, err := ConvertStructToBSONMapE(
+			struct {
+				TestField1 string      `bson:"testField1"`
+				TestField2 interface{} `bson:"testField2"`
+			}{
+				TestField1: "Test String",
+				TestField2: 10,
+			}, &MappingOpts{ValidateEncodable: true},
+		)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(bson.M{"testField1": "Test String", "testField2": 10}))
+	})
+
+	It("should not validate the output when it isn't set", func() {
+		result, err := ConvertStructToBSONMapE(
+			struct {
+				TestField1 interface{} `bson:"testField1"`
+			}{
+				TestField1: complex(1, 1),
+			}, nil,
+		)
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(bson.M{"testField1": complex(1, 1)}))
+	})
+})
@@ -0,0 +1,92 @@
+package mapper
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+)
+
+// ConvertStructToVersionedUpdate maps the struct into an update document in the same way as ConvertStructToUpdateBSON,
+// alongside a filter holding the current value of it's field with the "version" tag option, for optimistic concurrency:
+//
+// 	 type User struct {
+// 	 	 ID      string `bson:"_id"`
+// 	 	 Name    string `bson:"name"`
+// 	 	 Version int    `bson:"version,version"`
+// 	 }
+//
+// 	 // update: bson.M{ "$set": bson.M{ "_id": "1", "name": "Jane" }, "$inc": bson.M{ "version": 1 } }
+// 	 // filter: bson.M{ "version": 3 }
+//
+// The filter should be combined with the document's "_id" when updating, if nothing matches the
+// document has been modified since it was read. Returns a nil filter if the struct doesn't have
+// a version field, and nil for both if there is nothing to update
+func ConvertStructToVersionedUpdate(s interface{}, opts *MappingOpts) (bson.M, bson.M) {
+	update, filter, _ := ConvertStructToVersionedUpdateE(s, opts)
+	return update, filter
+}
+
+// ConvertStructToVersionedUpdateE behaves the same as ConvertStructToVersionedUpdate,
+// however it returns an error if the struct can't be mapped
+func ConvertStructToVersionedUpdateE(s interface{}, opts *MappingOpts) (bson.M, bson.M, error) {
+	if err := checkStruct(s); err != nil {
+		return nil, nil, err
+	}
+	return NewBSONMapperStruct(s).ToVersionedUpdateE(opts)
+}
+
+// ToVersionedUpdate maps the struct into an update document (see ToUpdateOperators) which increments
+// it's field with the "version" tag option, alongside a filter holding the version's current value
+//
+// Returns a nil filter if the struct doesn't have a version field, and nil for both if there is nothing to update
+func (s *StructToBSON) ToVersionedUpdate(opts *MappingOpts) (bson.M, bson.M) {
+	update, filter, _ := s.ToVersionedUpdateE(opts)
+	return update, filter
+}
+
+// ToVersionedUpdateE behaves the same as ToVersionedUpdate, however it returns
+// an error if the struct can't be mapped
+func (s *StructToBSON) ToVersionedUpdateE(opts *MappingOpts) (bson.M, bson.M, error) {
+	update, err := s.ToUpdateOperatorsE(opts)
+	if err != nil || update == nil {
+		return nil, nil, err
+	}
+
+	opts = withDefaults(opts)
+	key, ok := s.versionKey(opts)
+	if !ok {
+		return update, nil, nil
+	}
+	return update, bson.M{key: s.versionValue(opts)}, nil
+}
+
+// versionKey returns the resolved key of the first field with the "version" tag option
+func (s *StructToBSON) versionKey(opts *MappingOpts) (string, bool) {
+	var version string
+	var ok bool
+	s.eachFieldKey(opts, func(key string, tagOpts tagOptions) {
+		if !ok && tagOpts.Has("version") {
+			version, ok = key, true
+		}
+	})
+	return version, ok
+}
+
+// versionValue returns the current value of the first field with the "version" tag option. A nil
+// pointer is returned as nil, which matches documents where the version is null or hasn't been set
+func (s *StructToBSON) versionValue(opts *MappingOpts) interface{} {
+	for _, field := range s.structFields(opts) {
+		if _, tagOpts := parseTag(s.fieldTag(field, opts)); !tagOpts.Has("version") {
+			continue
+		}
+
+		val := s.value.FieldByIndex(field.Index)
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return nil
+			}
+			val = val.Elem()
+		}
+		return val.Interface()
+	}
+	return nil
+}
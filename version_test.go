@@ -0,0 +1,74 @@
+package mapper
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var _ = Describe("ConvertStructToVersionedUpdate", func() {
+	type testStruct struct {
+		ID      string `bson:"_id,omitempty"`
+		Name    string `bson:"name,omitempty"`
+		Version int    `bson:"version,version"`
+	}
+
+	It("should increment the version and return a filter holding it's current value", func() {
+		update, filter := ConvertStructToVersionedUpdate(testStruct{ID: "1", Name: "Test", Version: 3}, nil)
+		Expect(update).To(Equal(bson.M{
+			"$set": bson.M{"_id": "1", "name": "Test"},
+			"$inc": bson.M{"version": 1},
+		}))
+		Expect(filter).To(Equal(bson.M{"version": 3}))
+	})
+
+	It("should increment the version even if it's zero value is omitted", func() {
+		update, filter := ConvertStructToVersionedUpdate(testStruct{Name: "Test"}, &MappingOpts{GenerateFilterOrPatch: true})
+		Expect(update).To(Equal(bson.M{
+			"$set": bson.M{"name": "Test"},
+			"$inc": bson.M{"version": 1},
+		}))
+		Expect(filter).To(Equal(bson.M{"version": 0}))
+	})
+
+	It("should return a nil version for a nil pointer", func() {
+		type pointerStruct struct {
+			Name    string `bson:"name"`
+			Version *int64 `bson:"rev,version"`
+		}
+
+		_, filter := ConvertStructToVersionedUpdate(pointerStruct{Name: "Test"}, nil)
+		Expect(filter).To(Equal(bson.M{"rev": nil}))
+
+		version := int64(7)
+		_, filter = ConvertStructToVersionedUpdate(&pointerStruct{Version: &version}, nil)
+		Expect(filter).To(Equal(bson.M{"rev": int64(7)}))
+	})
+
+	It("should resolve the version's key using the mapping options", func() {
+		update, filter := NewBSONMapperStruct(testStruct{Version: 1}).ToVersionedUpdate(&MappingOpts{
+			RenameKeys: map[string]string{"version": "v"},
+		})
+		Expect(update).To(Equal(bson.M{"$inc": bson.M{"v": 1}}))
+		Expect(filter).To(Equal(bson.M{"v": 1}))
+	})
+
+	It("should return a nil filter if the struct doesn't have a version field", func() {
+		update, filter := ConvertStructToVersionedUpdate(struct {
+			Name string `bson:"name"`
+		}{Name: "Test"}, nil)
+		Expect(update).To(Equal(bson.M{"$set": bson.M{"name": "Test"}}))
+		Expect(filter).To(BeNil())
+	})
+
+	It("should return nil for both if there is nothing to update", func() {
+		update, filter := ConvertStructToVersionedUpdate(testStruct{ID: "1"}, &MappingOpts{UseIDifAvailable: true})
+		Expect(update).To(BeNil())
+		Expect(filter).To(BeNil())
+	})
+
+	It("should return an error from the error API if it isn't passed a struct", func() {
+		_, _, err := ConvertStructToVersionedUpdateE("not a struct", nil)
+		Expect(err).To(MatchError(ErrNotAStruct))
+	})
+})